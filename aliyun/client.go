@@ -0,0 +1,288 @@
+package aliyun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/model/res"
+)
+
+const (
+	fileListUri         = "/adrive/v3/file/list"
+	fileGetUri          = "/v2/file/get"
+	downloadUri         = "/v2/file/get_download_url"
+	createWithFolderUri = "/adrive/v2/file/createWithFolders"
+	refreshUploadUri    = "/v2/file/get_upload_url"
+	uploadFinalUri      = "/v2/file/complete"
+	moveUri             = "/v2/file/move"
+	renameUri           = "/v3/file/update"
+	trashUri            = "/v2/recyclebin/trash"
+	deleteUri           = "/v3/file/delete"
+	copyUri             = "/v2/file/copy"
+	batchUri            = "/v3/batch"
+
+	Referer = "https://www.aliyundrive.com/"
+)
+
+const (
+	CheckNameModeRefuse     = "refuse"
+	CheckNameModeAutoRename = "auto_rename"
+	CheckNameModeOverwrite  = "overwrite"
+
+	TypeFile   = "file"
+	TypeFolder = "folder"
+
+	DefaultDownloadExpireSec = 14400
+	// 一次批量请求最多包含的子请求数
+	BatchLimit = 100
+)
+
+//Client 阿里云盘接口客户端
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// 上传分片和下载文件内容使用, 不设置超时
+	TransferHTTPClient *http.Client
+	// 为 nil 时请求不带 Authorization 头
+	Tokens *TokenManager
+	// 为空时使用 token 中的默认 drive
+	DriveId string
+}
+
+func BuildClient(baseURL string, tokens *TokenManager) *Client {
+	return &Client{
+		BaseURL:            strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:         &http.Client{Timeout: 30 * time.Second},
+		TransferHTTPClient: &http.Client{},
+		Tokens:             tokens,
+	}
+}
+
+//BuildClientFromConfig 按 config.AliyunConf 创建 Client, refresh token 保存在 TokenFilePath
+func BuildClientFromConfig() *Client {
+	conf := config.AliyunConf
+	client := BuildClient(conf.BaseURL, BuildTokenManager(conf.AuthURL, conf.RefreshToken, conf.TokenFilePath()))
+	client.DriveId = conf.DriveId
+	return client
+}
+
+//DefaultDriveId 返回请求使用的 drive id
+func (c *Client) DefaultDriveId() string {
+	if len(c.DriveId) > 0 || c.Tokens == nil {
+		return c.DriveId
+	}
+	// 默认 drive 在第一次刷新 token 时获得, 刷新失败的错误由随后的请求返回
+	_, _ = c.Tokens.AccessToken()
+	return c.Tokens.DriveId()
+}
+
+//FileList 列出 ParentFileId 下的文件
+func (c *Client) FileList(request req.FileList) (*res.TFileList, error) {
+	if len(request.Fields) <= 0 {
+		request.Fields = "*"
+	}
+	result := &res.TFileList{}
+	return result, c.post(fileListUri, request, result)
+}
+
+func (c *Client) FileGet(request req.FileGet) (*res.TFile, error) {
+	result := &res.TFile{}
+	return result, c.post(fileGetUri, request, result)
+}
+
+//Download 获取文件的下载地址, ExpireSec 默认 14400 秒
+func (c *Client) Download(request req.Download) (*res.Download, error) {
+	if request.ExpireSec <= 0 {
+		request.ExpireSec = DefaultDownloadExpireSec
+	}
+	result := &res.Download{}
+	return result, c.post(downloadUri, request, result)
+}
+
+//UploadPre 创建文件并获取分片上传地址
+func (c *Client) UploadPre(request req.UploadPre) (*res.UploadPre, error) {
+	if len(request.CheckNameMode) <= 0 {
+		request.CheckNameMode = CheckNameModeRefuse
+	}
+	if len(request.ContentHashName) <= 0 {
+		request.ContentHashName = "none"
+	}
+	if len(request.ProofVersion) <= 0 {
+		request.ProofVersion = ProofVersionV1
+	}
+	if len(request.Type) <= 0 {
+		request.Type = TypeFile
+	}
+	result := &res.UploadPre{}
+	return result, c.post(createWithFolderUri, request, result)
+}
+
+//RefreshUploadUrl 重新获取已过期的分片上传地址
+func (c *Client) RefreshUploadUrl(request req.RefreshUploadUrl) (*res.UploadPre, error) {
+	result := &res.UploadPre{}
+	return result, c.post(refreshUploadUri, request, result)
+}
+
+//UploadFinal 所有分片上传完成后提交文件
+func (c *Client) UploadFinal(request req.UploadFinal) (*res.TFile, error) {
+	result := &res.TFile{}
+	return result, c.post(uploadFinalUri, request, result)
+}
+
+func (c *Client) Move(request req.Move) error {
+	return c.post(moveUri, request, nil)
+}
+
+//BatchMove 用一次批量请求把 fileIds 移动到 toParentFileId 下, fileIds 不能超过 BatchLimit 个
+//返回每个文件的结果, 移动成功的文件对应 nil, 批量请求本身失败时返回错误
+func (c *Client) BatchMove(driveId string, fileIds []string, toParentFileId string) (map[string]error, error) {
+	requestIds := make([]model.MoveRequestId, 0, len(fileIds))
+	request := req.Batch{Resource: "file"}
+	for i, fileId := range fileIds {
+		requestId := model.MoveRequestId{CcpFileId: fileId, Id: strconv.Itoa(i)}
+		requestIds = append(requestIds, requestId)
+		request.Requests = append(request.Requests, req.BatchRequest{
+			Body:    req.Move{DriveId: driveId, FileId: fileId, ToParentFileId: toParentFileId},
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Id:      requestId.Id,
+			Method:  http.MethodPost,
+			Url:     "/file/move",
+		})
+	}
+	result := &res.Batch{}
+	if err := c.post(batchUri, request, result); err != nil {
+		return nil, err
+	}
+	responses := make(map[string]res.BatchResponse, len(result.Responses))
+	for _, response := range result.Responses {
+		responses[response.Id] = response
+	}
+	results := make(map[string]error, len(requestIds))
+	for _, requestId := range requestIds {
+		response, ok := responses[requestId.Id]
+		switch {
+		case !ok:
+			results[requestId.CcpFileId] = fmt.Errorf("aliyun: no response for batch request %s", requestId.Id)
+		case response.Status >= 400:
+			apiError := &APIError{StatusCode: response.Status}
+			_ = json.Unmarshal(response.Body, apiError)
+			results[requestId.CcpFileId] = apiError
+		default:
+			results[requestId.CcpFileId] = nil
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) Rename(request req.Rename) (*res.TFile, error) {
+	if len(request.CheckNameMode) <= 0 {
+		request.CheckNameMode = CheckNameModeRefuse
+	}
+	result := &res.TFile{}
+	return result, c.post(renameUri, request, result)
+}
+
+//Remove 将文件移到回收站
+func (c *Client) Remove(request req.Remove) error {
+	return c.post(trashUri, request, nil)
+}
+
+//Delete 彻底删除文件, 不经过回收站
+func (c *Client) Delete(request req.Remove) error {
+	return c.post(deleteUri, request, nil)
+}
+
+//Copy 在云盘中复制文件或文件夹, 文件夹的内容在后台复制
+func (c *Client) Copy(request req.Copy) (*res.Copy, error) {
+	if len(request.ToDriveId) <= 0 {
+		request.ToDriveId = request.DriveId
+	}
+	result := &res.Copy{}
+	return result, c.post(copyUri, request, result)
+}
+
+//CreateFile 创建文件夹, Type 默认为 folder
+func (c *Client) CreateFile(request req.CreateFile) (*res.CreateFile, error) {
+	if len(request.CheckNameMode) <= 0 {
+		request.CheckNameMode = CheckNameModeRefuse
+	}
+	if len(request.Type) <= 0 {
+		request.Type = TypeFolder
+	}
+	result := &res.CreateFile{}
+	return result, c.post(createWithFolderUri, request, result)
+}
+
+func (c *Client) post(uri string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := c.send(uri, data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return decodeError(response)
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("aliyun: decode %s: %w", uri, err)
+	}
+	return nil
+}
+
+//send 发送请求, access token 失效时刷新后重试一次
+func (c *Client) send(uri string, data []byte) (*http.Response, error) {
+	token := ""
+	for retry := 0; ; retry++ {
+		if c.Tokens != nil {
+			var err error
+			if token, err = c.Tokens.AccessToken(); err != nil {
+				return nil, err
+			}
+		}
+		request, err := http.NewRequest(http.MethodPost, c.BaseURL+uri, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Referer", Referer)
+		if len(token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := c.HTTPClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized || c.Tokens == nil || retry > 0 {
+			return response, nil
+		}
+		response.Body.Close()
+		logrus.Warnf("access token rejected by %s, refreshing", uri)
+		if err := c.Tokens.Invalidate(token); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func decodeError(response *http.Response) error {
+	apiError := &APIError{StatusCode: response.StatusCode}
+	data, _ := ioutil.ReadAll(response.Body)
+	if err := json.Unmarshal(data, apiError); err != nil {
+		apiError.Message = string(data)
+	}
+	return apiError
+}
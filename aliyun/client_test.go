@@ -0,0 +1,47 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webdav-aliyundriver/model/req"
+)
+
+func TestClientFileGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fileGetUri {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["drive_id"] != "1" || body["file_id"] != "f1" {
+			t.Errorf("unexpected body %v", body)
+		}
+		_, _ = w.Write([]byte(`{"file_id":"f1","name":"a.txt","type":"file","size":3,"updated_at":"2021-05-01T10:00:00.000Z"}`))
+	}))
+	defer server.Close()
+
+	file, err := BuildClient(server.URL, nil).FileGet(req.FileGet{DriveId: "1", FileId: "f1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "a.txt" || file.Size != 3 || file.UpdatedAt.IsZero() {
+		t.Errorf("unexpected file %+v", file)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"NotFound.File","message":"The resource file cannot be found."}`))
+	}))
+	defer server.Close()
+
+	_, err := BuildClient(server.URL, nil).FileGet(req.FileGet{DriveId: "1", FileId: "f1"})
+	var apiError *APIError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiError) || apiError.Code != "NotFound.File" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package aliyun
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/model/res"
+)
+
+//serverCopy 使用复制接口将 file 复制到 parent 下并命名为 name
+func (s *Store) serverCopy(file *res.TFile, parent *res.TFile, name string) (*res.TFile, error) {
+	driveId := s.client.DefaultDriveId()
+	result, err := s.client.Copy(req.Copy{
+		DriveId:        driveId,
+		FileId:         file.FileId,
+		ToParentFileId: parent.FileId,
+		AutoRename:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied, err := s.client.FileGet(req.FileGet{DriveId: driveId, FileId: result.FileId})
+	if err != nil {
+		return nil, err
+	}
+	if copied.Name == name {
+		return copied, nil
+	}
+	renamed, err := s.client.Rename(req.Rename{DriveId: driveId, FileId: copied.FileId, Name: name})
+	if err != nil {
+		s.discard(copied.FileId)
+		return nil, err
+	}
+	return renamed, nil
+}
+
+//rapidCopy 用 file 的 sha1 秒传创建副本, 内容不经过服务器, 只读取计算校验码需要的几个字节
+func (s *Store) rapidCopy(file *res.TFile, parent *res.TFile, name string) (*res.TFile, error) {
+	if len(file.ContentHash) <= 0 || !strings.EqualFold(file.ContentHashName, "sha1") {
+		return nil, fmt.Errorf("%w: %s has no sha1", ErrNotSupported, file.Name)
+	}
+	accessToken := ""
+	if s.client.Tokens != nil {
+		var err error
+		if accessToken, err = s.client.Tokens.AccessToken(); err != nil {
+			return nil, err
+		}
+	}
+	code, err := proofCode(accessToken, remoteContent{store: s, fileId: file.FileId}, file.Size)
+	if err != nil {
+		return nil, err
+	}
+	driveId := s.client.DefaultDriveId()
+	pre, err := s.client.UploadPre(req.UploadPre{
+		CheckNameMode:   CheckNameModeRefuse,
+		ContentHash:     file.ContentHash,
+		ContentHashName: "sha1",
+		DriveId:         driveId,
+		Name:            name,
+		ParentFileId:    parent.FileId,
+		ProofCode:       code,
+		Size:            file.Size,
+		PartInfoList:    []req.PartInfo{{PartNumber: 1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !pre.RapidUpload {
+		// 不能秒传时云盘已经创建了等待上传的文件
+		s.discard(pre.FileId)
+		return nil, fmt.Errorf("%w: rapid upload of %s refused", ErrNotSupported, file.Name)
+	}
+	return s.client.FileGet(req.FileGet{DriveId: driveId, FileId: pre.FileId})
+}
+
+//discard 彻底删除复制失败时留下的文件
+func (s *Store) discard(fileId string) {
+	if err := s.client.Delete(req.Remove{DriveId: s.client.DefaultDriveId(), FileId: fileId}); err != nil {
+		logrus.Warnf("discard %s failed: %v", fileId, err)
+	}
+}
+
+//apiUnsupported 接口拒绝复制或者移动时返回 true, 此时改用秒传或者逐个移动子资源
+func apiUnsupported(err error) bool {
+	return errors.Is(err, ErrForbidden) || errors.Is(err, ErrInvalidParameter)
+}
+
+//remoteContent 按需下载文件的一部分, 实现 io.ReaderAt
+type remoteContent struct {
+	store  *Store
+	fileId string
+}
+
+func (c remoteContent) ReadAt(p []byte, offset int64) (int, error) {
+	content, err := c.store.fetchFile(c.fileId, offset, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+	return io.ReadFull(content, p)
+}
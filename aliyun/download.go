@@ -0,0 +1,130 @@
+package aliyun
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"webdav-aliyundriver/model/req"
+)
+
+//下载地址在过期前多久不再使用
+const downloadUrlExpireAhead = 5 * time.Minute
+
+//downloadUrls 缓存文件的下载地址直到快要过期
+type downloadUrls struct {
+	client *Client
+	mu     sync.Mutex
+	urls   map[string]downloadUrl
+}
+
+type downloadUrl struct {
+	url      string
+	expireAt time.Time
+}
+
+func buildDownloadUrls(client *Client) *downloadUrls {
+	return &downloadUrls{
+		client: client,
+		urls:   map[string]downloadUrl{},
+	}
+}
+
+//Get 返回 fileId 的下载地址, 缓存中没有或者快要过期时重新获取
+func (d *downloadUrls) Get(fileId string) (string, error) {
+	now := time.Now()
+	d.mu.Lock()
+	cached, ok := d.urls[fileId]
+	d.mu.Unlock()
+	if ok && now.Add(downloadUrlExpireAhead).Before(cached.expireAt) {
+		return cached.url, nil
+	}
+
+	download, err := d.client.Download(req.Download{
+		DriveId:   d.client.DefaultDriveId(),
+		FileId:    fileId,
+		ExpireSec: DefaultDownloadExpireSec,
+	})
+	if err != nil {
+		return "", err
+	}
+	expireAt := download.Expiration
+	if expireAt.IsZero() {
+		expireAt = now.Add(DefaultDownloadExpireSec * time.Second)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, cached := range d.urls {
+		if now.After(cached.expireAt) {
+			delete(d.urls, id)
+		}
+	}
+	d.urls[fileId] = downloadUrl{url: download.Url, expireAt: expireAt}
+	return download.Url, nil
+}
+
+//Evict 下载地址提前失效时调用
+func (d *downloadUrls) Evict(fileId string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.urls, fileId)
+}
+
+//Fetch 下载 url 从 offset 开始的 length 字节, length 为 -1 时读取到结尾
+func (c *Client) Fetch(url string, offset int64, length int64) (io.ReadCloser, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Referer", Referer)
+	if offset > 0 || length >= 0 {
+		end := ""
+		if length >= 0 {
+			end = fmt.Sprint(offset + length - 1)
+		}
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%s", offset, end))
+	}
+	response, err := c.TransferHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 400 {
+		response.Body.Close()
+		return nil, &APIError{StatusCode: response.StatusCode, Message: response.Status}
+	}
+	body := response.Body
+	if response.StatusCode != http.StatusPartialContent && offset > 0 {
+		// 不支持 Range 时跳过 offset 之前的内容
+		if _, err := io.CopyN(ioutil.Discard, body, offset); err != nil {
+			body.Close()
+			return nil, err
+		}
+	}
+	if length >= 0 {
+		return limitReadCloser{Reader: io.LimitReader(body, length), Closer: body}, nil
+	}
+	return body, nil
+}
+
+type limitReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+//fetchFile 下载文件 fileId 的内容, 缓存的下载地址失效时重新获取后重试一次
+func (s *Store) fetchFile(fileId string, offset int64, length int64) (io.ReadCloser, error) {
+	for retry := 0; ; retry++ {
+		url, err := s.downloads.Get(fileId)
+		if err != nil {
+			return nil, err
+		}
+		content, err := s.client.Fetch(url, offset, length)
+		if !errors.Is(err, ErrForbidden) || retry > 0 {
+			return content, err
+		}
+		s.downloads.Evict(fileId)
+	}
+}
@@ -0,0 +1,49 @@
+package aliyun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webdav-aliyundriver/model"
+)
+
+func TestStoreResourceRange(t *testing.T) {
+	downloads := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fileListUri:
+			_, _ = w.Write([]byte(`{"items":[{"file_id":"f","name":"a.mp4","type":"file","size":10}]}`))
+		case downloadUri:
+			downloads++
+			_, _ = fmt.Fprintf(w, `{"url":"%s/content","expiration":"%s"}`, server.URL, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/content":
+			if r.Header.Get("Referer") != Referer {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// 不支持 Range 的下载地址
+			_, _ = w.Write([]byte("0123456789"))
+		}
+	}))
+	defer server.Close()
+
+	s := buildStore(t, BuildClient(server.URL, nil))
+	for i := 0; i < 2; i++ {
+		content, err := s.ResourceRange(model.Transaction{}, "/a.mp4", 3, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(content)
+		content.Close()
+		if string(data) != "3456" {
+			t.Errorf("range content %q", data)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("download url requested %d times", downloads)
+	}
+}
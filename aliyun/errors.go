@@ -0,0 +1,60 @@
+package aliyun
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNotFound           = errors.New("aliyun: file not found")
+	ErrAlreadyExist       = errors.New("aliyun: file already exists")
+	ErrAccessTokenInvalid = errors.New("aliyun: access token invalid")
+	ErrForbidden          = errors.New("aliyun: forbidden")
+	ErrInvalidParameter   = errors.New("aliyun: invalid parameter")
+	ErrTooManyRequests    = errors.New("aliyun: too many requests")
+	ErrUploadUrlExpired   = errors.New("aliyun: upload url expired")
+)
+
+//APIError 接口返回的错误, 可以通过 errors.Is 与上面的错误比较
+//分片上传地址返回的是 xml 格式的错误
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code" xml:"Code"`
+	Message    string `json:"message" xml:"Message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("aliyun: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case strings.HasPrefix(e.Code, "NotFound."):
+		return ErrNotFound
+	case strings.HasPrefix(e.Code, "AlreadyExist."), e.Code == "PartAlreadyExist":
+		return ErrAlreadyExist
+	case strings.HasPrefix(e.Code, "AccessToken"):
+		return ErrAccessTokenInvalid
+	case strings.HasPrefix(e.Code, "Forbidden"):
+		return ErrForbidden
+	case strings.HasPrefix(e.Code, "InvalidParameter"):
+		return ErrInvalidParameter
+	case e.Code == "TooManyRequests":
+		return ErrTooManyRequests
+	case e.Code == "AccessDenied" && strings.Contains(e.Message, "expired"):
+		// 分片上传地址过期
+		return ErrUploadUrlExpired
+	}
+	switch e.StatusCode {
+	case 401:
+		return ErrAccessTokenInvalid
+	case 403:
+		return ErrForbidden
+	case 404:
+		return ErrNotFound
+	case 429:
+		return ErrTooManyRequests
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const journalSuffix = ".upload.json"
+
+//UploadState 一次未完成的分片上传, 保存在 UploadJournal 中用于重启后继续上传
+type UploadState struct {
+	DriveId      string    `json:"drive_id"`
+	FileId       string    `json:"file_id"`
+	UploadId     string    `json:"upload_id"`
+	ParentFileId string    `json:"parent_file_id"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	PartSize     int64     `json:"part_size"`
+	PartCount    int32     `json:"part_count"`
+	Completed    []int32   `json:"completed_parts"`
+	SpoolFile    string    `json:"spool_file"`
+	StartedAt    time.Time `json:"started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+//Remaining 返回还没有上传完成的分片
+func (s *UploadState) Remaining() []int32 {
+	completed := map[int32]bool{}
+	for _, partNumber := range s.Completed {
+		completed[partNumber] = true
+	}
+	var remaining []int32
+	for partNumber := int32(1); partNumber <= s.PartCount; partNumber++ {
+		if !completed[partNumber] {
+			remaining = append(remaining, partNumber)
+		}
+	}
+	return remaining
+}
+
+//UploadJournal 将进行中的上传记录在 dir 下, 每个上传一个文件, 缓存上传内容的临时文件也放在 dir 下
+type UploadJournal struct {
+	dir    string
+	mu     sync.Mutex
+	states map[string]*UploadState
+}
+
+//BuildUploadJournal 创建 dir 并读取其中上次运行遗留的上传记录
+func BuildUploadJournal(dir string) (*UploadJournal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	j := &UploadJournal{dir: dir, states: map[string]*UploadState{}}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+journalSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		state := &UploadState{}
+		if err := json.Unmarshal(data, state); err != nil || len(state.UploadId) <= 0 {
+			// 写入时使用重命名, 不应该出现不完整的记录
+			_ = os.Remove(name)
+			continue
+		}
+		j.states[state.UploadId] = state
+	}
+	return j, nil
+}
+
+func (j *UploadJournal) Dir() string {
+	return j.dir
+}
+
+//Begin 记录新的上传
+func (j *UploadJournal) Begin(state UploadState) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state.StartedAt = time.Now()
+	state.UpdatedAt = state.StartedAt
+	j.states[state.UploadId] = &state
+	return j.save(&state)
+}
+
+//Complete 记录 uploadId 的分片 partNumber 已经上传完成
+func (j *UploadJournal) Complete(uploadId string, partNumber int32) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state, ok := j.states[uploadId]
+	if !ok {
+		return nil
+	}
+	state.Completed = append(state.Completed, partNumber)
+	state.UpdatedAt = time.Now()
+	return j.save(state)
+}
+
+//Finish 删除 uploadId 的记录和缓存文件, 上传完成或者放弃时调用
+func (j *UploadJournal) Finish(uploadId string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if state, ok := j.states[uploadId]; ok {
+		_ = os.Remove(state.SpoolFile)
+		delete(j.states, uploadId)
+	}
+	_ = os.Remove(j.file(uploadId))
+}
+
+//List 返回全部未完成的上传, 按开始时间排序
+func (j *UploadJournal) List() []UploadState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	states := make([]UploadState, 0, len(j.states))
+	for _, state := range j.states {
+		s := *state
+		s.Completed = append([]int32(nil), state.Completed...)
+		states = append(states, s)
+	}
+	sort.Slice(states, func(a, b int) bool {
+		return states[a].StartedAt.Before(states[b].StartedAt)
+	})
+	return states
+}
+
+//ServeHTTP 以 json 列出未完成的上传, 用于管理接口
+func (j *UploadJournal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(j.List())
+}
+
+func (j *UploadJournal) save(state *UploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(j.file(state.UploadId), data)
+}
+
+func (j *UploadJournal) file(uploadId string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(uploadId)
+	return filepath.Join(j.dir, name+journalSuffix)
+}
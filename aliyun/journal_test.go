@@ -0,0 +1,187 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+)
+
+func TestUploaderResume(t *testing.T) {
+	dir := t.TempDir()
+	spoolFile := filepath.Join(dir, "upload-1")
+	if err := ioutil.WriteFile(spoolFile, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := BuildUploadJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = journal.Begin(UploadState{
+		FileId: "f", UploadId: "u", Name: "a.bin", Size: 10, PartSize: 4, PartCount: 3,
+		Completed: []int32{1}, SpoolFile: spoolFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启
+	journal, err = BuildUploadJournal(dir)
+	if err != nil || len(journal.List()) != 1 {
+		t.Fatalf("journal %v, err %v", journal.List(), err)
+	}
+	recorder := httptest.NewRecorder()
+	journal.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/uploads", nil))
+	var listed []UploadState
+	if err := json.Unmarshal(recorder.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].UploadId != "u" {
+		t.Errorf("admin listing %s", recorder.Body.String())
+	}
+
+	received := map[string]string{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case refreshUploadUri:
+			var body struct {
+				PartInfoList []struct {
+					PartNumber int32 `json:"part_number"`
+				} `json:"part_info_list"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if len(body.PartInfoList) != 2 || body.PartInfoList[0].PartNumber != 2 {
+				t.Errorf("unexpected refresh %+v", body)
+			}
+			_, _ = fmt.Fprintf(w, `{"part_info_list":[{"part_number":2,"upload_url":"%[1]s/oss/2"},{"part_number":3,"upload_url":"%[1]s/oss/3"}]}`, server.URL)
+		case "/oss/2", "/oss/3":
+			data, _ := ioutil.ReadAll(r.Body)
+			received[r.URL.Path] = string(data)
+		case uploadFinalUri:
+			_, _ = w.Write([]byte(`{"file_id":"f","name":"a.bin","parent_file_id":"root","size":10}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	files := BuildUploader(BuildClient(server.URL, nil), journal).Resume()
+	if len(files) != 1 || received["/oss/2"] != "4567" || received["/oss/3"] != "89" {
+		t.Errorf("files %v, received %v", files, received)
+	}
+	if len(journal.List()) != 0 {
+		t.Errorf("journal not finished: %v", journal.List())
+	}
+	if _, err := os.Stat(spoolFile); !os.IsNotExist(err) {
+		t.Errorf("spool file not removed: %v", err)
+	}
+}
+
+func TestBuildStoreResumesUploads(t *testing.T) {
+	defer func(dir string) {
+		config.AliyunConf.UploadJournalDir = dir
+	}(config.AliyunConf.UploadJournalDir)
+	dir := t.TempDir()
+	config.AliyunConf.UploadJournalDir = dir
+	spoolFile := filepath.Join(dir, "upload-1")
+	if err := ioutil.WriteFile(spoolFile, []byte("0123"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := BuildUploadJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = journal.Begin(UploadState{
+		FileId: "f", UploadId: "u", Name: "a.bin", Size: 4, PartSize: 4, PartCount: 1,
+		Completed: []int32{1}, SpoolFile: spoolFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != uploadFinalUri {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			return
+		}
+		finished = true
+		_, _ = w.Write([]byte(`{"file_id":"f","name":"a.bin","parent_file_id":"root","size":4}`))
+	}))
+	defer server.Close()
+
+	if _, err := BuildStore(BuildClient(server.URL, nil)); err != nil || !finished {
+		t.Fatalf("upload finished %t, err %v", finished, err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 0 {
+		t.Errorf("journal files left %v", names)
+	}
+}
+
+func TestStoreUploadJournal(t *testing.T) {
+	defer func(dir string) {
+		config.AliyunConf.UploadJournalDir = dir
+	}(config.AliyunConf.UploadJournalDir)
+	config.AliyunConf.UploadJournalDir = ""
+	if s := buildStore(t, BuildClient("http://127.0.0.1", nil)); s.UploadJournal() != nil {
+		t.Errorf("journal without UploadJournalDir")
+	}
+
+	config.AliyunConf.UploadJournalDir = t.TempDir()
+	s := buildStore(t, BuildClient("http://127.0.0.1", nil))
+	err := s.UploadJournal().Begin(UploadState{FileId: "f", UploadId: "u", Name: "a.bin", Size: 4, PartSize: 4, PartCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	s.UploadJournal().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/uploads", nil))
+	var listed []UploadState
+	if err := json.Unmarshal(recorder.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].UploadId != "u" {
+		t.Errorf("admin listing %s", recorder.Body.String())
+	}
+}
+
+func TestUploaderJournalsSmallUploads(t *testing.T) {
+	defer func(partSize int64, rapidUpload bool) {
+		config.AliyunConf.UploadPartSize = partSize
+		config.AliyunConf.RapidUpload = rapidUpload
+	}(config.AliyunConf.UploadPartSize, config.AliyunConf.RapidUpload)
+	config.AliyunConf.UploadPartSize = 4
+	config.AliyunConf.RapidUpload = false
+	journal, err := BuildUploadJournal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case createWithFolderUri:
+			_, _ = fmt.Fprintf(w, `{"file_id":"f","upload_id":"u","part_info_list":[{"part_number":1,"upload_url":"%s/oss/1"}]}`, server.URL)
+		case "/oss/1":
+			// 内容小于 UploadSpillThreshold, 也要写入文件并记录
+			states := journal.List()
+			if len(states) != 1 {
+				t.Errorf("journal %v", states)
+				return
+			}
+			if data, err := ioutil.ReadFile(states[0].SpoolFile); err != nil || string(data) != "abc" {
+				t.Errorf("spool file %q, err %v", data, err)
+			}
+		case uploadFinalUri:
+			_, _ = w.Write([]byte(`{"file_id":"f","name":"a.txt","size":3}`))
+		}
+	}))
+	defer server.Close()
+
+	if _, err := BuildUploader(BuildClient(server.URL, nil), journal).Upload(RootFileId, "a.txt", strings.NewReader("abc"), 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.List()) != 0 {
+		t.Errorf("journal not finished: %v", journal.List())
+	}
+}
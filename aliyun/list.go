@@ -0,0 +1,80 @@
+package aliyun
+
+import (
+	"strings"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/model/res"
+)
+
+//FileIterator 按页列出文件夹下的文件, 当前页用完时才请求下一页
+type FileIterator struct {
+	client  *Client
+	request req.FileList
+	items   []res.TFile
+	index   int
+	// 最后一页已经取回
+	last bool
+	err  error
+}
+
+//Iterate 列出 parentFileId 下的文件, page 中的 Marker 为起始位置
+func (c *Client) Iterate(parentFileId string, page model.Page) *FileIterator {
+	page.OrderDirection = strings.ToUpper(page.OrderDirection)
+	return &FileIterator{
+		client: c,
+		request: req.FileList{
+			Page:         page,
+			DriveId:      c.DefaultDriveId(),
+			ParentFileId: parentFileId,
+		},
+		index: -1,
+	}
+}
+
+//Next 移动到下一个文件, 没有更多文件或者出错时返回 false
+func (it *FileIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	for it.index >= len(it.items) {
+		if it.last {
+			return false
+		}
+		list, err := it.client.FileList(it.request)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.items = list.Items
+		it.index = 0
+		it.request.Marker = list.NextMarker
+		it.last = len(list.NextMarker) <= 0
+	}
+	return true
+}
+
+//File 返回当前文件, 只能在 Next 返回 true 之后调用
+func (it *FileIterator) File() *res.TFile {
+	return &it.items[it.index]
+}
+
+//Marker 返回下一页的起始位置, 可以用于之后继续列出
+func (it *FileIterator) Marker() string {
+	return it.request.Marker
+}
+
+func (it *FileIterator) Err() error {
+	return it.err
+}
+
+//ListFiles 列出文件夹 parentFileId 下的全部文件
+func (c *Client) ListFiles(parentFileId string, page model.Page) ([]res.TFile, error) {
+	var files []res.TFile
+	it := c.Iterate(parentFileId, page)
+	for it.Next() {
+		files = append(files, *it.File())
+	}
+	return files, it.Err()
+}
@@ -0,0 +1,41 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webdav-aliyundriver/model"
+)
+
+func TestFileIterator(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"items":[{"file_id":"1","name":"1.jpg"},{"file_id":"2","name":"2.jpg"}],"next_marker":"m1"}`,
+		"m1": `{"items":[],"next_marker":"m2"}`,
+		"m2": `{"items":[{"file_id":"3","name":"3.jpg"}],"next_marker":""}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["order_by"] != "updated_at" || body["order_direction"] != "DESC" || body["limit"] != 2.0 {
+			t.Errorf("unexpected body %v", body)
+		}
+		marker, _ := body["marker"].(string)
+		requests++
+		_, _ = w.Write([]byte(pages[marker]))
+	}))
+	defer server.Close()
+
+	it := BuildClient(server.URL, nil).Iterate(RootFileId, model.Page{Limit: 2, OrderBy: "updated_at", OrderDirection: "desc"})
+	if !it.Next() || it.File().FileId != "1" || requests != 1 {
+		t.Fatalf("first file: requests %d", requests)
+	}
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.File().FileId)
+	}
+	if it.Err() != nil || len(ids) != 2 || ids[1] != "3" || requests != 3 {
+		t.Errorf("ids %v, requests %d, err %v", ids, requests, it.Err())
+	}
+}
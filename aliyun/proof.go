@@ -0,0 +1,34 @@
+package aliyun
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+const ProofVersionV1 = "v1"
+
+//proofCode 计算秒传的 v1 校验码
+//access token 的 md5 前 16 位十六进制数对 size 取模得到偏移, 校验码为从偏移处开始最多 8 字节内容的 base64
+func proofCode(accessToken string, content io.ReaderAt, size int64) (string, error) {
+	if size <= 0 {
+		return "", nil
+	}
+	sum := md5.Sum([]byte(accessToken))
+	n, err := strconv.ParseUint(hex.EncodeToString(sum[:])[:16], 16, 64)
+	if err != nil {
+		return "", err
+	}
+	offset := int64(n % uint64(size))
+	end := offset + 8
+	if end > size {
+		end = size
+	}
+	buffer := make([]byte, end-offset)
+	if _, err := content.ReadAt(buffer, offset); err != nil && err != io.EOF {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buffer), nil
+}
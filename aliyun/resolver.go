@@ -0,0 +1,270 @@
+package aliyun
+
+import (
+	"sync"
+	"time"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/model/res"
+)
+
+//RootFileId 根目录的 FileId
+const RootFileId = "root"
+
+//PathResolver 将路径逐级解析为 FileId, 结果按路径树缓存 ttl 时间, 不存在的路径同样会被缓存
+type PathResolver struct {
+	client *Client
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	root *pathNode
+}
+
+type pathNode struct {
+	// nil 表示路径不存在
+	file     *res.TFile
+	expireAt time.Time
+	children map[string]*pathNode
+	// listed 为 true 时 children 包含了文件夹下的全部文件, 在 listExpireAt 之前不存在的名称无需再次查询
+	listed       bool
+	listExpireAt time.Time
+}
+
+func BuildPathResolver(client *Client, ttl time.Duration) *PathResolver {
+	return &PathResolver{
+		client: client,
+		ttl:    ttl,
+		root: &pathNode{
+			file:     &res.TFile{FileId: RootFileId, Type: TypeFolder},
+			expireAt: time.Unix(1<<62, 0),
+			children: map[string]*pathNode{},
+		},
+	}
+}
+
+//Resolve 返回 p 对应的文件, 不存在时返回 ErrNotFound
+func (r *PathResolver) Resolve(p string) (*res.TFile, error) {
+	node, err := r.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file := *node.file
+	return &file, nil
+}
+
+//List 列出文件夹 p 下的全部文件, 并用结果更新缓存
+func (r *PathResolver) List(p string) ([]res.TFile, error) {
+	node, err := r.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	folder := *node.file
+	r.mu.Unlock()
+	if folder.Type != TypeFolder {
+		return nil, ErrNotFound
+	}
+
+	files, err := r.client.ListFiles(folder.FileId, config.AliyunConf.ListPage())
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fill(node, files)
+	return files, nil
+}
+
+func (r *PathResolver) resolve(p string) (*pathNode, error) {
+	node := r.root
+	for _, name := range model.BuildPathInfo(p).Segments() {
+		child, err := r.child(node, name)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+//Put 记录 p 对应的文件, 用于新建、上传和重命名之后立即更新缓存
+func (r *PathResolver) Put(p string, file *res.TFile) {
+	info := model.BuildPathInfo(p)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parent := r.lookup(info.ParentPath)
+	if parent == nil || len(info.Name) <= 0 {
+		return
+	}
+	node, ok := parent.children[info.Name]
+	if !ok || node.file == nil || node.file.FileId != file.FileId {
+		node = &pathNode{children: map[string]*pathNode{}}
+		parent.children[info.Name] = node
+	}
+	f := *file
+	node.file = &f
+	node.expireAt = time.Now().Add(r.ttl)
+}
+
+//Remove 记录 p 已被删除
+func (r *PathResolver) Remove(p string) {
+	info := model.BuildPathInfo(p)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if parent := r.lookup(info.ParentPath); parent != nil && len(info.Name) > 0 {
+		parent.children[info.Name] = r.missing()
+	}
+}
+
+//Move 将 src 的缓存(包括其子路径)转移到 dst, FileId 在移动和重命名后不变
+func (r *PathResolver) Move(src string, dst string) {
+	srcInfo := model.BuildPathInfo(src)
+	dstInfo := model.BuildPathInfo(dst)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	srcParent := r.lookup(srcInfo.ParentPath)
+	if srcParent == nil {
+		r.invalidate(dstInfo)
+		return
+	}
+	node, ok := srcParent.children[srcInfo.Name]
+	srcParent.children[srcInfo.Name] = r.missing()
+
+	dstParent := r.lookup(dstInfo.ParentPath)
+	if dstParent == nil {
+		return
+	}
+	if !ok || node.file == nil {
+		r.invalidate(dstInfo)
+		return
+	}
+	f := *node.file
+	f.Name = dstInfo.Name
+	f.ParentFileId = dstParent.file.FileId
+	node.file = &f
+	// 目标文件夹的列表可能比节点更晚过期, 过期的节点会被当作不存在
+	node.expireAt = time.Now().Add(r.ttl)
+	dstParent.children[dstInfo.Name] = node
+}
+
+//Invalidate 丢弃 p 及其子路径的缓存, 父文件夹需要重新列出
+func (r *PathResolver) Invalidate(p string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invalidate(model.BuildPathInfo(p))
+}
+
+//InvalidateFolder 文件夹 fileId 下的文件有变化但路径未知时调用, 之后需要重新列出该文件夹
+func (r *PathResolver) InvalidateFolder(fileId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes := []*pathNode{r.root}
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+		if node.file.FileId == fileId {
+			node.listed = false
+			node.children = map[string]*pathNode{}
+			return
+		}
+		for _, child := range node.children {
+			if child.file != nil {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+}
+
+func (r *PathResolver) invalidate(info model.PathInfo) {
+	if len(info.Name) <= 0 {
+		r.root.children = map[string]*pathNode{}
+		r.root.listed = false
+		return
+	}
+	if parent := r.lookup(info.ParentPath); parent != nil {
+		delete(parent.children, info.Name)
+		parent.listed = false
+	}
+}
+
+//child 在 parent 下查找 name, 缓存未命中时列出 parent 下的全部文件
+func (r *PathResolver) child(parent *pathNode, name string) (*pathNode, error) {
+	r.mu.Lock()
+	now := time.Now()
+	if child, ok := parent.children[name]; ok && now.Before(child.expireAt) {
+		missing := child.file == nil
+		r.mu.Unlock()
+		if missing {
+			return nil, ErrNotFound
+		}
+		return child, nil
+	}
+	if parent.file.Type != TypeFolder {
+		r.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if child, ok := parent.children[name]; parent.listed && now.Before(parent.listExpireAt) && (!ok || child.file == nil) {
+		// 过期的节点仍有文件时重新列出, 不能当作不存在
+		parent.children[name] = r.missing()
+		r.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	parentFileId := parent.file.FileId
+	r.mu.Unlock()
+
+	files, err := r.client.ListFiles(parentFileId, config.AliyunConf.ListPage())
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fill(parent, files)
+	child, ok := parent.children[name]
+	if !ok {
+		child = r.missing()
+		parent.children[name] = child
+	}
+	if child.file == nil {
+		return nil, ErrNotFound
+	}
+	return child, nil
+}
+
+//fill 用文件夹的完整列表更新 parent 的子节点, FileId 没有变化的子节点保留其子树
+func (r *PathResolver) fill(parent *pathNode, files []res.TFile) {
+	expireAt := time.Now().Add(r.ttl)
+	children := make(map[string]*pathNode, len(files))
+	for i := range files {
+		file := files[i]
+		node, ok := parent.children[file.Name]
+		if !ok || node.file == nil || node.file.FileId != file.FileId {
+			node = &pathNode{children: map[string]*pathNode{}}
+		}
+		node.file = &file
+		node.expireAt = expireAt
+		children[file.Name] = node
+	}
+	parent.children = children
+	parent.listed = true
+	parent.listExpireAt = expireAt
+}
+
+//lookup 只在缓存中查找 p, 不发出请求
+func (r *PathResolver) lookup(p string) *pathNode {
+	node := r.root
+	for _, name := range model.BuildPathInfo(p).Segments() {
+		child, ok := node.children[name]
+		if !ok || child.file == nil {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+func (r *PathResolver) missing() *pathNode {
+	return &pathNode{expireAt: time.Now().Add(r.ttl)}
+}
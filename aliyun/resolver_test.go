@@ -0,0 +1,106 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webdav-aliyundriver/model/res"
+)
+
+func TestPathResolver(t *testing.T) {
+	listed := map[string]int{}
+	folders := map[string]string{
+		RootFileId: `[{"file_id":"a","name":"a","type":"folder"},{"file_id":"b","name":"b","type":"folder"}]`,
+		"a":        `[{"file_id":"f","name":"f.txt","type":"file"}]`,
+		"b":        `[]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		parent := body["parent_file_id"].(string)
+		listed[parent]++
+		_, _ = w.Write([]byte(`{"items":` + folders[parent] + `}`))
+	}))
+	defer server.Close()
+
+	resolver := BuildPathResolver(BuildClient(server.URL, nil), time.Minute)
+	file, err := resolver.Resolve("/a/f.txt")
+	if err != nil || file.FileId != "f" {
+		t.Fatalf("resolve /a/f.txt: %+v, %v", file, err)
+	}
+	if _, err := resolver.Resolve("/a/f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolver.Resolve("/a/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("resolve missing: %v", err)
+	}
+	if _, err := resolver.Resolve("/b"); err != nil {
+		t.Fatal(err)
+	}
+	if listed[RootFileId] != 1 || listed["a"] != 1 {
+		t.Errorf("listed %v", listed)
+	}
+
+	// 移动后 FileId 不变, 不需要重新列出
+	resolver.Move("/a/f.txt", "/b/g.txt")
+	if file, err := resolver.Resolve("/b/g.txt"); err != nil || file.FileId != "f" || file.ParentFileId != "b" {
+		t.Errorf("resolve moved file: %+v, %v", file, err)
+	}
+	if _, err := resolver.Resolve("/a/f.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("resolve move source: %v", err)
+	}
+
+	resolver.Put("/b/new", &res.TFile{FileId: "n", Name: "new", Type: TypeFolder})
+	if file, err := resolver.Resolve("/b/new"); err != nil || file.FileId != "n" {
+		t.Errorf("resolve put folder: %+v, %v", file, err)
+	}
+	resolver.Remove("/b/new")
+	if _, err := resolver.Resolve("/b/new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("resolve removed folder: %v", err)
+	}
+	if listed["b"] != 0 {
+		t.Errorf("listed %v", listed)
+	}
+
+	resolver.Invalidate("/a")
+	if _, err := resolver.Resolve("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if listed[RootFileId] != 2 {
+		t.Errorf("listed %v", listed)
+	}
+}
+
+func TestPathResolverMoveExpiry(t *testing.T) {
+	folders := map[string]string{
+		RootFileId: `[{"file_id":"a","name":"a","type":"folder"},{"file_id":"b","name":"b","type":"folder"}]`,
+		"a":        `[{"file_id":"f","name":"f.txt","type":"file"}]`,
+		"b":        `[]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"items":` + folders[body["parent_file_id"].(string)] + `}`))
+	}))
+	defer server.Close()
+
+	const ttl = 200 * time.Millisecond
+	resolver := BuildPathResolver(BuildClient(server.URL, nil), ttl)
+	if _, err := resolver.Resolve("/a/f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl * 3 / 5)
+	// 目标文件夹比 f.txt 晚列出
+	if _, err := resolver.Resolve("/b/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("resolve /b/missing: %v", err)
+	}
+	resolver.Move("/a/f.txt", "/b/f.txt")
+	// f.txt 原来的缓存时间已过, /b 的列表仍然有效
+	time.Sleep(ttl * 3 / 5)
+	if file, err := resolver.Resolve("/b/f.txt"); err != nil || file.FileId != "f" {
+		t.Errorf("resolve moved file: %+v, %v", file, err)
+	}
+}
@@ -0,0 +1,106 @@
+package aliyun
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+//spool 缓存上传内容, 分片上传地址过期后需要重新读取分片
+type spool struct {
+	io.ReaderAt
+	size int64
+	file *os.File
+	// 内容的 sha1, 大写十六进制
+	sha1 string
+}
+
+//spoolContent 读取 content, 不超过 threshold 时缓存在内存, 否则写入 tempDir 下的临时文件
+//length 不为 -1 时读取到的长度必须与之一致
+func spoolContent(content io.Reader, length int64, threshold int64, tempDir string) (*spool, error) {
+	hash := sha1.New()
+	content = io.TeeReader(content, hash)
+	s, err := spoolTo(content, length, threshold, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	s.sha1 = strings.ToUpper(hex.EncodeToString(hash.Sum(nil)))
+	return s, nil
+}
+
+func spoolTo(content io.Reader, length int64, threshold int64, tempDir string) (*spool, error) {
+	buffer := &bytes.Buffer{}
+	if length < 0 || length <= threshold {
+		n, err := io.CopyN(buffer, content, threshold+1)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n <= threshold {
+			s := &spool{ReaderAt: bytes.NewReader(buffer.Bytes()), size: n}
+			return s, s.check(length)
+		}
+	}
+
+	file, err := ioutil.TempFile(tempDir, "upload-")
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{ReaderAt: file, file: file}
+	n, err := io.Copy(file, io.MultiReader(buffer, content))
+	s.size = n
+	if err == nil {
+		err = s.check(length)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+//persist 将缓存在内存中的内容写入 dir 下的临时文件, 之后从文件读取
+func (s *spool) persist(dir string) error {
+	if s.file != nil {
+		return nil
+	}
+	file, err := ioutil.TempFile(dir, "upload-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(s, 0, s.size)); err != nil {
+		file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	s.ReaderAt = file
+	s.file = file
+	return nil
+}
+
+func (s *spool) check(length int64) error {
+	if length >= 0 && s.size != length {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (s *spool) Size() int64 {
+	return s.size
+}
+
+func (s *spool) Sha1() string {
+	return s.sha1
+}
+
+//Close 删除临时文件
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
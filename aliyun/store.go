@@ -0,0 +1,422 @@
+package aliyun
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/model/res"
+	"webdav-aliyundriver/store"
+)
+
+var ErrNotSupported = fmt.Errorf("aliyun: %w", store.ErrNotSupported)
+
+//Store 基于阿里云盘的 store.Store 实现
+type Store struct {
+	client    *Client
+	resolver  *PathResolver
+	uploader  *Uploader
+	downloads *downloadUrls
+}
+
+//BuildStore 创建 Store, 配置了 UploadJournalDir 时记录上传进度, 并在返回前继续上次运行时未完成的上传
+func BuildStore(client *Client) (*Store, error) {
+	var journal *UploadJournal
+	if dir := config.AliyunConf.UploadJournalDir; len(dir) > 0 {
+		var err error
+		if journal, err = BuildUploadJournal(dir); err != nil {
+			return nil, err
+		}
+	}
+	s := &Store{
+		client:    client,
+		resolver:  BuildPathResolver(client, config.AliyunConf.PathCacheTTL),
+		uploader:  BuildUploader(client, journal),
+		downloads: buildDownloadUrls(client),
+	}
+	s.resumeUploads()
+	return s, nil
+}
+
+//BuildStoreFromConfig 使用 BuildClientFromConfig 创建的 Client 创建 Store
+func BuildStoreFromConfig() (*Store, error) {
+	return BuildStore(BuildClientFromConfig())
+}
+
+//UploadJournal 返回记录上传进度的 UploadJournal, 用于管理接口列出未完成的上传, 没有配置 UploadJournalDir 时为 nil
+func (s *Store) UploadJournal() *UploadJournal {
+	return s.uploader.journal
+}
+
+//resumeUploads 继续上次运行时未完成的上传, 需要在开始处理请求前完成
+func (s *Store) resumeUploads() {
+	for _, file := range s.uploader.Resume() {
+		s.resolver.InvalidateFolder(file.ParentFileId)
+	}
+}
+
+func (s *Store) StoredObject(transaction model.Transaction, path string) (*model.StoredObject, error) {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return storedObject(file), nil
+}
+
+func (s *Store) ChildrenNames(transaction model.Transaction, folderPath string) ([]string, error) {
+	files, err := s.resolver.List(folderPath)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names, nil
+}
+
+//WalkChildren 实现 store.ChildWalker, 每页取回后立即交给 fn, 只缓存其中的文件夹
+func (s *Store) WalkChildren(transaction model.Transaction, folderPath string,
+	fn func(name string, so *model.StoredObject) error) error {
+	folder, err := s.resolver.Resolve(folderPath)
+	if err != nil {
+		return storeError(err)
+	}
+	if folder.Type != TypeFolder {
+		return store.ErrNotFound
+	}
+	it := s.client.Iterate(folder.FileId, config.AliyunConf.ListPage())
+	for it.Next() {
+		file := it.File()
+		if file.Type == TypeFolder {
+			s.resolver.Put(model.BuildPathInfo(folderPath+"/"+file.Name).Path, file)
+		}
+		if err := fn(file.Name, storedObject(file)); err != nil {
+			return err
+		}
+	}
+	return storeError(it.Err())
+}
+
+//ChildrenHash 实现 store.ChildrenHasher, 摘要由子资源的 file_id、名称和修改时间计算
+func (s *Store) ChildrenHash(transaction model.Transaction, folderPath string) (string, error) {
+	files, err := s.resolver.List(folderPath)
+	if err != nil {
+		return "", storeError(err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FileId < files[j].FileId
+	})
+	hash := sha1.New()
+	for _, file := range files {
+		_, _ = fmt.Fprintf(hash, "%s\x00%s\x00%d\n", file.FileId, file.Name, file.UpdatedAt.UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *Store) ResourceContent(transaction model.Transaction, path string) (io.ReadCloser, error) {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if file.Type == TypeFolder {
+		return nil, store.ErrNotFound
+	}
+	content, err := s.fetchFile(file.FileId, 0, -1)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return content, nil
+}
+
+//ResourceRange 实现 store.RangeReader, 只下载文件 path 从 offset 开始的 length 字节
+func (s *Store) ResourceRange(transaction model.Transaction, path string, offset int64, length int64) (io.ReadCloser, error) {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if file.Type == TypeFolder {
+		return nil, store.ErrNotFound
+	}
+	content, err := s.fetchFile(file.FileId, offset, length)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return content, nil
+}
+
+//DownloadURL 实现 store.Redirector, 下载接口失败时使用文件信息中的地址
+func (s *Store) DownloadURL(transaction model.Transaction, path string) (string, error) {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return "", storeError(err)
+	}
+	if file.Type == TypeFolder {
+		return "", store.ErrNotFound
+	}
+	url, err := s.downloads.Get(file.FileId)
+	if err != nil && len(file.DownloadUrl) > 0 {
+		return file.DownloadUrl, nil
+	}
+	return url, storeError(err)
+}
+
+func (s *Store) SetResourceContent(transaction model.Transaction, path string, content io.Reader,
+	contentType string, length int64) (int64, error) {
+	info := model.BuildPathInfo(path)
+	parent, err := s.folder(info.ParentPath)
+	if err != nil {
+		return 0, err
+	}
+	if file, err := s.resolver.Resolve(path); err == nil && file.Type == TypeFolder {
+		return 0, store.ErrExists
+	}
+	file, err := s.uploader.Upload(parent.FileId, info.Name, content, length)
+	if err != nil {
+		return 0, storeError(err)
+	}
+	s.resolver.Put(path, file)
+	s.downloads.Evict(file.FileId)
+	return file.Size, nil
+}
+
+func (s *Store) CreateFolder(transaction model.Transaction, folderPath string) error {
+	info := model.BuildPathInfo(folderPath)
+	parent, err := s.folder(info.ParentPath)
+	if err != nil {
+		return err
+	}
+	if _, err := s.resolver.Resolve(folderPath); err == nil {
+		return store.ErrExists
+	}
+	created, err := s.client.CreateFile(req.CreateFile{
+		DriveId:      s.client.DefaultDriveId(),
+		Name:         info.Name,
+		ParentFileId: parent.FileId,
+		Type:         TypeFolder,
+	})
+	if err != nil {
+		return storeError(err)
+	}
+	if created.Exist {
+		// 缓存过期期间其他客户端创建了同名文件
+		s.resolver.Invalidate(folderPath)
+		return store.ErrExists
+	}
+	now := time.Now()
+	s.resolver.Put(folderPath, &res.TFile{
+		FileId:       created.CcpFileId,
+		Name:         info.Name,
+		ParentFileId: parent.FileId,
+		Type:         TypeFolder,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	return nil
+}
+
+func (s *Store) RemoveObject(transaction model.Transaction, path string) error {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return storeError(err)
+	}
+	if file.FileId == RootFileId {
+		return store.ErrConflict
+	}
+	request := req.Remove{DriveId: s.client.DefaultDriveId(), FileId: file.FileId}
+	if config.AliyunConf.DeletePermanently {
+		err = s.client.Delete(request)
+	} else {
+		err = s.client.Remove(request)
+	}
+	if err != nil {
+		return storeError(err)
+	}
+	s.resolver.Remove(path)
+	s.downloads.Evict(file.FileId)
+	return nil
+}
+
+func (s *Store) Move(transaction model.Transaction, path string, toParentPath string) error {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return storeError(err)
+	}
+	parent, err := s.folder(toParentPath)
+	if err != nil {
+		return err
+	}
+	destinationPath := model.BuildPathInfo(toParentPath + "/" + file.Name).Path
+	if _, err := s.resolver.Resolve(destinationPath); err == nil {
+		return store.ErrExists
+	}
+	err = s.client.Move(req.Move{
+		DriveId:        s.client.DefaultDriveId(),
+		FileId:         file.FileId,
+		ToParentFileId: parent.FileId,
+	})
+	if err != nil {
+		return moveError(file, err)
+	}
+	s.resolver.Move(path, destinationPath)
+	return nil
+}
+
+//MoveAll 用批量接口移动 paths, 每次请求最多 BatchLimit 个
+func (s *Store) MoveAll(transaction model.Transaction, paths []string, toParentPath string) map[string]error {
+	failures := map[string]error{}
+	parent, err := s.folder(toParentPath)
+	if err != nil {
+		for _, path := range paths {
+			failures[path] = err
+		}
+		return failures
+	}
+	files := map[string]*res.TFile{}
+	var fileIds []string
+	for _, path := range paths {
+		file, err := s.resolver.Resolve(path)
+		if err != nil {
+			failures[path] = storeError(err)
+			continue
+		}
+		if _, err := s.resolver.Resolve(model.BuildPathInfo(toParentPath + "/" + file.Name).Path); err == nil {
+			failures[path] = store.ErrExists
+			continue
+		}
+		files[path] = file
+		fileIds = append(fileIds, file.FileId)
+	}
+
+	results := map[string]error{}
+	for start := 0; start < len(fileIds); start += BatchLimit {
+		end := start + BatchLimit
+		if end > len(fileIds) {
+			end = len(fileIds)
+		}
+		batch, err := s.client.BatchMove(s.client.DefaultDriveId(), fileIds[start:end], parent.FileId)
+		for _, fileId := range fileIds[start:end] {
+			if err != nil {
+				results[fileId] = err
+			} else {
+				results[fileId] = batch[fileId]
+			}
+		}
+	}
+	for path, file := range files {
+		if err := results[file.FileId]; err != nil {
+			failures[path] = moveError(file, err)
+			continue
+		}
+		s.resolver.Move(path, model.BuildPathInfo(toParentPath+"/"+file.Name).Path)
+	}
+	return failures
+}
+
+//Copy 优先使用复制接口, 接口不可用时文件改用秒传, 文件夹返回 ErrNotSupported 由调用方逐个复制
+func (s *Store) Copy(transaction model.Transaction, path string, destinationPath string) error {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return storeError(err)
+	}
+	info := model.BuildPathInfo(destinationPath)
+	parent, err := s.folder(info.ParentPath)
+	if err != nil {
+		return err
+	}
+	if _, err := s.resolver.Resolve(destinationPath); err == nil {
+		return store.ErrExists
+	}
+	copied, err := s.serverCopy(file, parent, info.Name)
+	if err != nil && apiUnsupported(err) {
+		if file.Type == TypeFolder {
+			return fmt.Errorf("%w: %v", ErrNotSupported, err)
+		}
+		logrus.Infof("copy %s with rapid upload: %v", path, err)
+		copied, err = s.rapidCopy(file, parent, info.Name)
+	}
+	if err != nil {
+		return storeError(err)
+	}
+	s.resolver.Put(destinationPath, copied)
+	return nil
+}
+
+func (s *Store) Rename(transaction model.Transaction, path string, name string) error {
+	file, err := s.resolver.Resolve(path)
+	if err != nil {
+		return storeError(err)
+	}
+	info := model.BuildPathInfo(path)
+	destinationPath := model.BuildPathInfo(info.ParentPath + "/" + name).Path
+	renamed, err := s.client.Rename(req.Rename{
+		DriveId: s.client.DefaultDriveId(),
+		FileId:  file.FileId,
+		Name:    name,
+	})
+	if err != nil {
+		return storeError(err)
+	}
+	s.resolver.Move(path, destinationPath)
+	s.resolver.Put(destinationPath, renamed)
+	return nil
+}
+
+//folder 返回文件夹 path, 不存在或者不是文件夹时返回 store.ErrConflict
+func (s *Store) folder(path string) (*res.TFile, error) {
+	file, err := s.resolver.Resolve(path)
+	if errors.Is(err, ErrNotFound) || (err == nil && file.Type != TypeFolder) {
+		return nil, store.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func storedObject(file *res.TFile) *model.StoredObject {
+	return &model.StoredObject{
+		IsFolder:      file.Type == TypeFolder,
+		LastModified:  file.UpdatedAt,
+		CreationDate:  file.CreatedAt,
+		ContentLength: file.Size,
+		MineType:      file.MimeType,
+		ContentHash:   contentHash(file),
+	}
+}
+
+//contentHash 返回文件的 sha1, 文件夹和没有 sha1 的文件返回空
+func contentHash(file *res.TFile) string {
+	if file.Type == TypeFolder || !strings.EqualFold(file.ContentHashName, "sha1") {
+		return ""
+	}
+	return strings.ToLower(file.ContentHash)
+}
+
+//moveError 云盘拒绝整体移动文件夹时返回 ErrNotSupported, 由调用方逐个移动子资源
+func moveError(file *res.TFile, err error) error {
+	if file.Type == TypeFolder && apiUnsupported(err) {
+		return fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+	return storeError(err)
+}
+
+//storeError 将接口错误转换为 store 包中的错误
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fmt.Errorf("%w: %v", store.ErrNotFound, err)
+	case errors.Is(err, ErrAlreadyExist):
+		return fmt.Errorf("%w: %v", store.ErrExists, err)
+	default:
+		return err
+	}
+}
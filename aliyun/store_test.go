@@ -0,0 +1,229 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/store"
+)
+
+func buildStore(t *testing.T, client *Client) *Store {
+	s, err := BuildStore(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStoreWalkChildren(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"items":[{"file_id":"1","name":"a.jpg","type":"file","size":3},{"file_id":"2","name":"sub","type":"folder"}],"next_marker":"m1"}`,
+		"m1": `{"items":[{"file_id":"3","name":"b.jpg","type":"file","size":5}],"next_marker":""}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fileListUri {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		marker, _ := body["marker"].(string)
+		requests++
+		_, _ = w.Write([]byte(pages[marker]))
+	}))
+	defer server.Close()
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	var names []string
+	err := s.WalkChildren(transaction, "/", func(name string, so *model.StoredObject) error {
+		names = append(names, name)
+		// 每页取回后立即交给调用方
+		if name == "sub" && (requests != 1 || !so.IsFolder) {
+			t.Errorf("sub emitted after %d requests", requests)
+		}
+		return nil
+	})
+	if err != nil || len(names) != 3 || names[2] != "b.jpg" || requests != 2 {
+		t.Fatalf("names %v, requests %d, err %v", names, requests, err)
+	}
+	// 文件夹已经缓存, 不需要再次列出
+	if so, err := s.StoredObject(transaction, "/sub"); err != nil || !so.IsFolder || requests != 2 {
+		t.Errorf("sub %v, requests %d, err %v", so, requests, err)
+	}
+}
+
+func TestStoreCreateFolder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.URL.Path == createWithFolderUri && body["name"] == "new":
+			_, _ = w.Write([]byte(`{"file_id":"f1","file_name":"new","type":"folder"}`))
+		case r.URL.Path == createWithFolderUri && body["name"] == "old":
+			_, _ = w.Write([]byte(`{"file_id":"f0","file_name":"old","type":"folder","exist":true}`))
+		case r.URL.Path == fileListUri:
+			_, _ = w.Write([]byte(`{"items":[],"next_marker":""}`))
+		default:
+			t.Errorf("unexpected request %s %v", r.URL.Path, body)
+		}
+	}))
+	defer server.Close()
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	if err := s.CreateFolder(transaction, "/new"); err != nil {
+		t.Fatal(err)
+	}
+	// 新文件夹立即可以解析, 不需要再次列出根文件夹
+	if so, err := s.StoredObject(transaction, "/new"); err != nil || !so.IsFolder {
+		t.Errorf("new folder %v, err %v", so, err)
+	}
+	if err := s.CreateFolder(transaction, "/old"); !errors.Is(err, store.ErrExists) {
+		t.Errorf("existing folder err %v", err)
+	}
+}
+
+func TestStoreRemoveObject(t *testing.T) {
+	var removed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fileListUri:
+			_, _ = w.Write([]byte(`{"items":[{"file_id":"1","name":"a.txt","type":"file"},{"file_id":"2","name":"b.txt","type":"file"}]}`))
+		case trashUri, deleteUri:
+			removed = append(removed, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	defer func(permanently bool) {
+		config.AliyunConf.DeletePermanently = permanently
+	}(config.AliyunConf.DeletePermanently)
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	config.AliyunConf.DeletePermanently = false
+	if err := s.RemoveObject(transaction, "/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	config.AliyunConf.DeletePermanently = true
+	if err := s.RemoveObject(transaction, "/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != trashUri || removed[1] != deleteUri {
+		t.Errorf("removed with %v", removed)
+	}
+	if _, err := s.StoredObject(transaction, "/a.txt"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("removed file still resolves: %v", err)
+	}
+}
+
+func TestStoreCopy(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		switch r.URL.Path {
+		case fileListUri:
+			_, _ = w.Write([]byte(`{"items":[{"file_id":"1","name":"a.txt","type":"file","size":3}]}`))
+		case copyUri:
+			_, _ = w.Write([]byte(`{"file_id":"2"}`))
+		case fileGetUri:
+			_, _ = w.Write([]byte(`{"file_id":"2","name":"a(1).txt","type":"file","size":3}`))
+		case renameUri:
+			_, _ = w.Write([]byte(`{"file_id":"2","name":"b.txt","type":"file","size":3}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	if err := s.Copy(transaction, "/a.txt", "/a.txt"); !errors.Is(err, store.ErrExists) {
+		t.Errorf("copy onto itself err %v", err)
+	}
+	if err := s.Copy(transaction, "/a.txt", "/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	// 复制后的自动命名被改成目标名称
+	if so, err := s.StoredObject(transaction, "/b.txt"); err != nil || so.ContentLength != 3 {
+		t.Errorf("copied file %v, err %v", so, err)
+	}
+	if len(calls) != 4 || calls[1] != copyUri || calls[3] != renameUri {
+		t.Errorf("calls %v", calls)
+	}
+}
+
+func TestStoreChildrenHash(t *testing.T) {
+	updated := "2021-06-01T12:00:00Z"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items":[{"file_id":"1","name":"a.txt","type":"file","updated_at":"` + updated +
+			`","content_hash":"A9993E36","content_hash_name":"sha1"}]}`))
+	}))
+	defer server.Close()
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	first, err := s.ChildrenHash(transaction, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if so, err := s.StoredObject(transaction, "/a.txt"); err != nil || so.ContentHash != "a9993e36" {
+		t.Errorf("content hash %v, err %v", so, err)
+	}
+	updated = "2021-06-02T12:00:00Z"
+	if second, err := s.ChildrenHash(transaction, "/"); err != nil || second == first {
+		t.Errorf("hash %s after update, was %s, err %v", second, first, err)
+	}
+}
+
+func TestStoreMoveAll(t *testing.T) {
+	lists := map[string]string{
+		RootFileId: `{"items":[{"file_id":"s","name":"src","type":"folder"},{"file_id":"d","name":"dst","type":"folder"}]}`,
+		"s":        `{"items":[{"file_id":"1","name":"a.txt","type":"file"},{"file_id":"2","name":"b.txt","type":"file"},{"file_id":"3","name":"c.txt","type":"file"}]}`,
+		"d":        `{"items":[{"file_id":"4","name":"c.txt","type":"file"}]}`,
+	}
+	var batch req.Batch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fileListUri:
+			var body req.FileList
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = w.Write([]byte(lists[body.ParentFileId]))
+		case moveUri:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":"ForbiddenNoPermission.File","message":"forbidden"}`))
+		case batchUri:
+			_ = json.NewDecoder(r.Body).Decode(&batch)
+			_, _ = w.Write([]byte(`{"responses":[{"id":"0","status":200,"body":{}},{"id":"1","status":404,"body":{"code":"NotFound.File"}}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var transaction model.Transaction
+	s := buildStore(t, BuildClient(server.URL, nil))
+	// 云盘拒绝移动文件夹时由调用方逐个移动子资源
+	if err := s.Move(transaction, "/src", "/dst"); !errors.Is(err, store.ErrNotSupported) {
+		t.Errorf("move folder err %v", err)
+	}
+	failures := s.MoveAll(transaction, []string{"/src/a.txt", "/src/b.txt", "/src/c.txt"}, "/dst")
+	if len(failures) != 2 || !errors.Is(failures["/src/b.txt"], store.ErrNotFound) || !errors.Is(failures["/src/c.txt"], store.ErrExists) {
+		t.Errorf("failures %v", failures)
+	}
+	if len(batch.Requests) != 2 || batch.Requests[0].Url != "/file/move" || batch.Requests[1].Id != "1" {
+		t.Errorf("batch %+v", batch)
+	}
+	if so, err := s.StoredObject(transaction, "/dst/a.txt"); err != nil || so.IsFolder {
+		t.Errorf("moved file %v, err %v", so, err)
+	}
+}
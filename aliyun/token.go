@@ -0,0 +1,187 @@
+package aliyun
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenUri = "/v2/account/token"
+	// access token 过期前多久开始刷新
+	tokenRefreshAhead = 5 * time.Minute
+)
+
+//TokenManager 维护 access token, 过期前自动用 refresh token 刷新并把新的 refresh token 写回 TokenFile
+type TokenManager struct {
+	AuthURL    string
+	TokenFile  string
+	HTTPClient *http.Client
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	driveId      string
+	expireAt     time.Time
+	// 正在进行的刷新, 并发刷新时共用同一次请求
+	refreshing *tokenCall
+}
+
+type tokenCall struct {
+	done chan struct{}
+	err  error
+}
+
+type tokenResponse struct {
+	AccessToken    string `json:"access_token"`
+	RefreshToken   string `json:"refresh_token"`
+	ExpiresIn      int64  `json:"expires_in"`
+	DefaultDriveId string `json:"default_drive_id"`
+}
+
+//BuildTokenManager 创建 TokenManager, tokenFile 中已保存的 refresh token 优先于 refreshToken
+func BuildTokenManager(authURL string, refreshToken string, tokenFile string) *TokenManager {
+	m := &TokenManager{
+		AuthURL:      strings.TrimSuffix(authURL, "/"),
+		TokenFile:    tokenFile,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		refreshToken: refreshToken,
+	}
+	if len(tokenFile) > 0 {
+		if data, err := ioutil.ReadFile(tokenFile); err == nil && len(bytes.TrimSpace(data)) > 0 {
+			m.refreshToken = string(bytes.TrimSpace(data))
+		}
+	}
+	return m
+}
+
+//AccessToken 返回可用的 access token, 即将过期时先刷新
+func (m *TokenManager) AccessToken() (string, error) {
+	m.mu.Lock()
+	if len(m.accessToken) > 0 && time.Now().Add(tokenRefreshAhead).Before(m.expireAt) {
+		token := m.accessToken
+		m.mu.Unlock()
+		return token, nil
+	}
+	m.mu.Unlock()
+
+	if err := m.refresh(""); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.accessToken, nil
+}
+
+//Invalidate 接口返回 401 时调用, stale 为被拒绝的 access token
+//如果 token 已经被其他请求刷新过则不会重复刷新
+func (m *TokenManager) Invalidate(stale string) error {
+	return m.refresh(stale)
+}
+
+//DriveId 返回刷新 token 时得到的默认 drive id
+func (m *TokenManager) DriveId() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.driveId
+}
+
+func (m *TokenManager) refresh(stale string) error {
+	m.mu.Lock()
+	if len(stale) > 0 && stale != m.accessToken {
+		m.mu.Unlock()
+		return nil
+	}
+	// 等待锁期间其他请求可能已经完成刷新, 再刷新会让刚得到的 refresh token 失效
+	if len(stale) <= 0 && len(m.accessToken) > 0 && time.Now().Add(tokenRefreshAhead).Before(m.expireAt) {
+		m.mu.Unlock()
+		return nil
+	}
+	if call := m.refreshing; call != nil {
+		m.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	m.refreshing = call
+	refreshToken := m.refreshToken
+	m.mu.Unlock()
+
+	token, err := m.requestToken(refreshToken)
+	if err == nil && len(m.TokenFile) > 0 {
+		// 旧的 refresh token 已经失效, 保存失败只能记录日志
+		if err := writeFileAtomic(m.TokenFile, []byte(token.RefreshToken)); err != nil {
+			logrus.Errorf("save refresh token to %s failed: %v", m.TokenFile, err)
+		}
+	}
+
+	m.mu.Lock()
+	if err == nil {
+		m.accessToken = token.AccessToken
+		m.refreshToken = token.RefreshToken
+		m.driveId = token.DefaultDriveId
+		m.expireAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	m.refreshing = nil
+	m.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+	return err
+}
+
+func (m *TokenManager) requestToken(refreshToken string) (*tokenResponse, error) {
+	if len(refreshToken) <= 0 {
+		return nil, errors.New("aliyun: refresh token is not configured")
+	}
+	data, _ := json.Marshal(map[string]string{
+		"refresh_token": refreshToken,
+		"grant_type":    "refresh_token",
+	})
+	response, err := m.HTTPClient.Post(m.AuthURL+tokenUri, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return nil, decodeError(response)
+	}
+	token := &tokenResponse{}
+	if err := json.NewDecoder(response.Body).Decode(token); err != nil {
+		return nil, err
+	}
+	if len(token.AccessToken) <= 0 || len(token.RefreshToken) <= 0 {
+		return nil, errors.New("aliyun: empty token in refresh response")
+	}
+	logrus.Infof("access token refreshed, expires in %ds", token.ExpiresIn)
+	return token, nil
+}
+
+//writeFileAtomic 先写临时文件再重命名, 避免进程中断时留下不完整的文件
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
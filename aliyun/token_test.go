@@ -0,0 +1,114 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model/req"
+)
+
+func TestTokenManagerRefresh(t *testing.T) {
+	var refreshCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenUri:
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			n := atomic.AddInt32(&refreshCount, 1)
+			if body["refresh_token"] != fmt.Sprintf("refresh-%d", n-1) {
+				t.Errorf("unexpected refresh token %q", body["refresh_token"])
+			}
+			_, _ = fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh-%d","expires_in":7200,"default_drive_id":"9"}`, n, n)
+		case fileGetUri:
+			// 第一个 access token 已失效
+			if r.Header.Get("Authorization") == "Bearer access-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid."}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"file_id":"f1"}`))
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "refresh-token")
+	tokens := BuildTokenManager(server.URL, "refresh-0", tokenFile)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tokens.AccessToken(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshCount != 1 || tokens.DriveId() != "9" {
+		t.Fatalf("refresh count %d, drive id %q", refreshCount, tokens.DriveId())
+	}
+	// 发现 token 即将过期后才拿到锁的请求不再重复刷新
+	if err := tokens.refresh(""); err != nil || refreshCount != 1 {
+		t.Fatalf("late refresh: count %d, %v", refreshCount, err)
+	}
+
+	if _, err := BuildClient(server.URL, tokens).FileGet(req.FileGet{FileId: "f1"}); err != nil {
+		t.Fatal(err)
+	}
+	if refreshCount != 2 {
+		t.Errorf("refresh count after 401: %d", refreshCount)
+	}
+	data, _ := ioutil.ReadFile(tokenFile)
+	if string(data) != "refresh-2" {
+		t.Errorf("persisted refresh token %q", data)
+	}
+	if BuildTokenManager(server.URL, "refresh-0", tokenFile).refreshToken != "refresh-2" {
+		t.Error("persisted refresh token not loaded")
+	}
+}
+
+func TestBuildClientFromConfig(t *testing.T) {
+	defer func(conf config.AliyunConfig, configFile string) {
+		*config.AliyunConf = conf
+		config.WebConf.ConfigFile = configFile
+	}(*config.AliyunConf, config.WebConf.ConfigFile)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenUri:
+			_, _ = w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","expires_in":7200,"default_drive_id":"9"}`))
+		case fileGetUri:
+			var body req.FileGet
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Authorization") != "Bearer access-1" || body.DriveId != "d" {
+				t.Errorf("authorization %q, drive id %q", r.Header.Get("Authorization"), body.DriveId)
+			}
+			_, _ = w.Write([]byte(`{"file_id":"f1"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	config.WebConf.ConfigFile = filepath.Join(dir, "config.yaml")
+	config.AliyunConf.BaseURL = server.URL
+	config.AliyunConf.AuthURL = server.URL
+	config.AliyunConf.RefreshToken = "refresh-0"
+	config.AliyunConf.DriveId = "d"
+	config.AliyunConf.TokenFile = ""
+
+	client := BuildClientFromConfig()
+	if _, err := client.FileGet(req.FileGet{DriveId: client.DefaultDriveId(), FileId: "f1"}); err != nil {
+		t.Fatal(err)
+	}
+	// refresh token 保存在配置文件所在的目录
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "refresh-token")); err != nil || string(data) != "refresh-1" {
+		t.Errorf("persisted refresh token %q, err %v", data, err)
+	}
+}
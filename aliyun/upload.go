@@ -0,0 +1,362 @@
+package aliyun
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model/req"
+	"webdav-aliyundriver/model/res"
+)
+
+const UploadOrderParallel = "parallel"
+
+//Uploader 将内容按 UploadPartSize 分片上传
+type Uploader struct {
+	client *Client
+	// 为 nil 时不记录上传进度, 重启后无法继续上传
+	journal *UploadJournal
+}
+
+func BuildUploader(client *Client, journal *UploadJournal) *Uploader {
+	return &Uploader{
+		client:  client,
+		journal: journal,
+	}
+}
+
+//Upload 将 content 上传为 parentFileId 下的 name, 同名文件会被覆盖, length 为 -1 表示长度未知
+func (u *Uploader) Upload(parentFileId string, name string, content io.Reader, length int64) (*res.TFile, error) {
+	conf := config.AliyunConf
+	tempDir := conf.UploadTempDir
+	if u.journal != nil {
+		// 缓存文件需要在重启后继续使用
+		tempDir = u.journal.Dir()
+	}
+	s, err := spoolContent(content, length, conf.UploadSpillThreshold, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	partSize := conf.UploadPartSize
+	partCount := (s.Size() + partSize - 1) / partSize
+	if partCount <= 0 {
+		partCount = 1
+	}
+	parts := make([]req.PartInfo, partCount)
+	for i := range parts {
+		parts[i].PartNumber = int32(i + 1)
+	}
+
+	request := req.UploadPre{
+		CheckNameMode: CheckNameModeOverwrite,
+		DriveId:       u.client.DefaultDriveId(),
+		Name:          name,
+		ParentFileId:  parentFileId,
+		Size:          s.Size(),
+		PartInfoList:  parts,
+	}
+	if conf.RapidUpload {
+		if err := u.rapidUpload(&request, s); err != nil {
+			return nil, err
+		}
+	}
+	pre, err := u.client.UploadPre(request)
+	if err != nil {
+		return nil, err
+	}
+	if pre.RapidUpload {
+		// 云盘中已有相同内容的文件, 不需要上传
+		logrus.Infof("rapid upload %s (%s) succeeded", name, s.Sha1())
+		return u.client.FileGet(req.FileGet{DriveId: request.DriveId, FileId: pre.FileId})
+	}
+	upload := &partUpload{
+		client:   u.client,
+		pre:      pre,
+		content:  s,
+		partSize: partSize,
+	}
+	if u.journal != nil {
+		// 重启后需要从文件中读取内容, 缓存在内存中的内容先写入文件
+		if err := s.persist(u.journal.Dir()); err != nil {
+			return nil, err
+		}
+		err := u.journal.Begin(UploadState{
+			DriveId:      request.DriveId,
+			FileId:       pre.FileId,
+			UploadId:     pre.UploadId,
+			ParentFileId: parentFileId,
+			Name:         name,
+			Size:         s.Size(),
+			PartSize:     partSize,
+			PartCount:    int32(partCount),
+			SpoolFile:    s.file.Name(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer u.journal.Finish(pre.UploadId)
+		upload.journal = u.journal
+	}
+	if err := upload.run(conf.UploadOrder, conf.UploadWorkers); err != nil {
+		return nil, err
+	}
+	return u.client.UploadFinal(req.UploadFinal{
+		DriveId:  request.DriveId,
+		FileId:   pre.FileId,
+		UploadId: pre.UploadId,
+	})
+}
+
+//Resume 继续上次运行时未完成的上传, 无法继续的上传会被放弃, 返回继续上传成功的文件
+func (u *Uploader) Resume() []*res.TFile {
+	if u.journal == nil {
+		return nil
+	}
+	var files []*res.TFile
+	for _, state := range u.journal.List() {
+		file, err := u.resume(state)
+		if err != nil {
+			logrus.Warnf("abort upload %s of %s: %v", state.UploadId, state.Name, err)
+		} else {
+			logrus.Infof("resumed upload %s of %s", state.UploadId, state.Name)
+			files = append(files, file)
+		}
+		u.journal.Finish(state.UploadId)
+	}
+	return files
+}
+
+func (u *Uploader) resume(state UploadState) (*res.TFile, error) {
+	file, err := os.Open(state.SpoolFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{ReaderAt: file, file: file, size: state.Size}
+	defer s.Close()
+	if info, err := file.Stat(); err != nil || info.Size() != state.Size {
+		return nil, fmt.Errorf("spool file %s is incomplete", state.SpoolFile)
+	}
+
+	remaining := state.Remaining()
+	if len(remaining) > 0 {
+		parts := make([]req.PartInfo, 0, len(remaining))
+		for _, partNumber := range remaining {
+			parts = append(parts, req.PartInfo{PartNumber: partNumber})
+		}
+		// 上次的上传地址可能已经过期, 直接重新获取
+		pre, err := u.client.RefreshUploadUrl(req.RefreshUploadUrl{
+			DriveId:      state.DriveId,
+			FileId:       state.FileId,
+			UploadId:     state.UploadId,
+			PartInfoList: parts,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pre.FileId = state.FileId
+		pre.UploadId = state.UploadId
+		upload := &partUpload{
+			client:   u.client,
+			pre:      pre,
+			content:  s,
+			partSize: state.PartSize,
+			journal:  u.journal,
+		}
+		if err := upload.run(config.AliyunConf.UploadOrder, config.AliyunConf.UploadWorkers); err != nil {
+			return nil, err
+		}
+	}
+	return u.client.UploadFinal(req.UploadFinal{
+		DriveId:  state.DriveId,
+		FileId:   state.FileId,
+		UploadId: state.UploadId,
+	})
+}
+
+//rapidUpload 为 request 填充秒传需要的 sha1 和校验码, 云盘中没有相同内容时仍按分片上传
+func (u *Uploader) rapidUpload(request *req.UploadPre, s *spool) error {
+	if u.client.Tokens == nil {
+		return nil
+	}
+	accessToken, err := u.client.Tokens.AccessToken()
+	if err != nil {
+		return err
+	}
+	code, err := proofCode(accessToken, s, s.Size())
+	if err != nil {
+		return err
+	}
+	request.ContentHash = s.Sha1()
+	request.ContentHashName = "sha1"
+	request.ProofCode = code
+	request.ProofVersion = ProofVersionV1
+	return nil
+}
+
+//partUpload 一次分片上传的状态
+type partUpload struct {
+	client   *Client
+	pre      *res.UploadPre
+	content  *spool
+	partSize int64
+	// 不为 nil 时记录已完成的分片
+	journal *UploadJournal
+
+	// 保护 pre.PartInfoList 中的上传地址
+	mu sync.Mutex
+}
+
+func (p *partUpload) run(order string, workers int) error {
+	p.mu.Lock()
+	parts := make([]int32, 0, len(p.pre.PartInfoList))
+	for _, part := range p.pre.PartInfoList {
+		parts = append(parts, part.PartNumber)
+	}
+	p.mu.Unlock()
+
+	if order != UploadOrderParallel || workers <= 1 {
+		for _, partNumber := range parts {
+			if err := p.upload(partNumber); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	partNumbers := make(chan int32)
+	failed := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNumber := range partNumbers {
+				if err := p.upload(partNumber); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, partNumber := range parts {
+		select {
+		case partNumbers <- partNumber:
+		case <-failed:
+			break feed
+		}
+	}
+	close(partNumbers)
+	wg.Wait()
+	return firstErr
+}
+
+//upload 上传一个分片, 上传地址过期时刷新后重试一次
+func (p *partUpload) upload(partNumber int32) error {
+	offset := int64(partNumber-1) * p.partSize
+	size := p.partSize
+	if offset+size > p.content.Size() {
+		size = p.content.Size() - offset
+	}
+	for retry := 0; ; retry++ {
+		uploadUrl := p.uploadUrl(partNumber)
+		err := p.client.UploadPart(uploadUrl, io.NewSectionReader(p.content, offset, size), size)
+		if err == nil || errors.Is(err, ErrAlreadyExist) {
+			return p.complete(partNumber)
+		}
+		if !errors.Is(err, ErrUploadUrlExpired) || retry > 0 {
+			return err
+		}
+		logrus.Infof("upload url of %s part %d expired, refreshing", p.pre.FileId, partNumber)
+		if err := p.refresh(uploadUrl); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *partUpload) complete(partNumber int32) error {
+	if p.journal == nil {
+		return nil
+	}
+	return p.journal.Complete(p.pre.UploadId, partNumber)
+}
+
+func (p *partUpload) uploadUrl(partNumber int32) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, part := range p.pre.PartInfoList {
+		if part.PartNumber == partNumber {
+			return part.UploadUrl
+		}
+	}
+	return ""
+}
+
+//refresh 重新获取全部分片的上传地址, 如果 stale 已被其他分片刷新则不再请求
+func (p *partUpload) refresh(stale string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expired := false
+	for _, part := range p.pre.PartInfoList {
+		if part.UploadUrl == stale {
+			expired = true
+			break
+		}
+	}
+	if !expired {
+		return nil
+	}
+	parts := make([]req.PartInfo, 0, len(p.pre.PartInfoList))
+	for _, part := range p.pre.PartInfoList {
+		parts = append(parts, req.PartInfo{PartNumber: part.PartNumber})
+	}
+	refreshed, err := p.client.RefreshUploadUrl(req.RefreshUploadUrl{
+		DriveId:      p.client.DefaultDriveId(),
+		FileId:       p.pre.FileId,
+		UploadId:     p.pre.UploadId,
+		PartInfoList: parts,
+	})
+	if err != nil {
+		return err
+	}
+	p.pre.PartInfoList = refreshed.PartInfoList
+	return nil
+}
+
+//UploadPart 将一个分片上传到 uploadUrl
+func (c *Client) UploadPart(uploadUrl string, part io.Reader, size int64) error {
+	request, err := http.NewRequest(http.MethodPut, uploadUrl, part)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	response, err := c.TransferHTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 400 {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+	apiError := &APIError{StatusCode: response.StatusCode}
+	data, _ := ioutil.ReadAll(response.Body)
+	if err := xml.Unmarshal(data, apiError); err != nil {
+		apiError.Message = string(data)
+	}
+	return apiError
+}
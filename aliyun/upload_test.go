@@ -0,0 +1,95 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"webdav-aliyundriver/config"
+)
+
+func TestUploaderUpload(t *testing.T) {
+	defer func(partSize, threshold int64) {
+		config.AliyunConf.UploadPartSize = partSize
+		config.AliyunConf.UploadSpillThreshold = threshold
+	}(config.AliyunConf.UploadPartSize, config.AliyunConf.UploadSpillThreshold)
+	config.AliyunConf.UploadPartSize = 4
+	config.AliyunConf.UploadSpillThreshold = 5
+
+	var (
+		mu       sync.Mutex
+		received = map[string]string{}
+		server   *httptest.Server
+	)
+	partInfoList := func(generation string, count int) string {
+		var parts []string
+		for i := 1; i <= count; i++ {
+			parts = append(parts, fmt.Sprintf(`{"part_number":%d,"upload_url":"%s/oss/%s/%d"}`, i, server.URL, generation, i))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == createWithFolderUri:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["size"] != 10.0 || body["check_name_mode"] != CheckNameModeOverwrite {
+				t.Errorf("unexpected upload pre %v", body)
+			}
+			_, _ = fmt.Fprintf(w, `{"file_id":"f","upload_id":"u","part_info_list":%s}`, partInfoList("old", 3))
+		case r.URL.Path == refreshUploadUri:
+			_, _ = fmt.Fprintf(w, `{"file_id":"f","upload_id":"u","part_info_list":%s}`, partInfoList("new", 3))
+		case strings.HasPrefix(r.URL.Path, "/oss/old/2"):
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Request has expired.</Message></Error>`))
+		case strings.HasPrefix(r.URL.Path, "/oss/"):
+			data, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			received[r.URL.Path[len(r.URL.Path)-1:]] = string(data)
+			mu.Unlock()
+		case r.URL.Path == uploadFinalUri:
+			_, _ = w.Write([]byte(`{"file_id":"f","name":"a.bin","size":10}`))
+		}
+	}))
+	defer server.Close()
+
+	file, err := BuildUploader(BuildClient(server.URL, nil), nil).Upload(RootFileId, "a.bin", strings.NewReader("0123456789"), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FileId != "f" || received["1"] != "0123" || received["2"] != "4567" || received["3"] != "89" {
+		t.Errorf("file %+v, received %v", file, received)
+	}
+}
+
+func TestUploaderRapidUpload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenUri:
+			_, _ = w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","expires_in":7200}`))
+		case createWithFolderUri:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["content_hash"] != "BBA45A81EA4B89DE4DD1CAC8B0D101B68A5949D0" || body["content_hash_name"] != "sha1" ||
+				body["proof_code"] != "cmFwaWQgdXA=" || body["proof_version"] != ProofVersionV1 {
+				t.Errorf("unexpected upload pre %v", body)
+			}
+			_, _ = w.Write([]byte(`{"file_id":"f","upload_id":"u","rapid_upload":true}`))
+		case fileGetUri:
+			_, _ = w.Write([]byte(`{"file_id":"f","name":"a.txt","size":18}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := BuildClient(server.URL, BuildTokenManager(server.URL, "refresh-0", ""))
+	file, err := BuildUploader(client, nil).Upload(RootFileId, "a.txt", strings.NewReader("hello rapid upload"), 18)
+	if err != nil || file.FileId != "f" {
+		t.Errorf("file %+v, err %v", file, err)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"path/filepath"
+	"time"
+	"webdav-aliyundriver/model"
+)
+
+type AliyunConfig struct {
+	// 接口地址, 测试时可以指向本地的模拟服务
+	BaseURL string
+	// 刷新 access token 的地址
+	AuthURL string
+	DriveId string
+	// 首次启动使用的 refresh token, 之后以 TokenFile 中保存的为准
+	RefreshToken string
+	// 保存刷新后 refresh token 的文件, 默认为配置文件同目录下的 refresh-token
+	TokenFile string
+	// 路径到 FileId 的缓存时间
+	PathCacheTTL time.Duration
+	// 列出文件夹时每页的数量, 最大 200
+	ListLimit int32
+	// name, created_at, updated_at 或 size
+	ListOrderBy string
+	// ASC 或 DESC
+	ListOrderDirection string
+	// 上传分片大小
+	UploadPartSize int64
+	// 上传内容不超过该大小时缓存在内存中, 否则写入 UploadTempDir 下的临时文件
+	UploadSpillThreshold int64
+	// 为空时使用系统临时目录
+	UploadTempDir string
+	// sequential 按分片顺序逐个上传, parallel 使用 UploadWorkers 个协程同时上传
+	UploadOrder   string
+	UploadWorkers int
+	// 上传前先用内容的 sha1 尝试秒传
+	RapidUpload bool
+	// 记录进行中的上传, 重启后继续上传, 为空时不记录
+	UploadJournalDir string
+	// DELETE 时彻底删除文件, 默认移到回收站
+	DeletePermanently bool
+}
+
+var AliyunConf = &AliyunConfig{
+	BaseURL:            "https://api.aliyundrive.com",
+	AuthURL:            "https://auth.aliyundrive.com",
+	PathCacheTTL:       time.Minute,
+	ListLimit:          200,
+	ListOrderBy:        "name",
+	ListOrderDirection: "ASC",
+
+	UploadPartSize:       10 * 1024 * 1024,
+	UploadSpillThreshold: 16 * 1024 * 1024,
+	UploadOrder:          "sequential",
+	UploadWorkers:        3,
+	RapidUpload:          true,
+}
+
+//TokenFilePath 返回 refresh token 的保存路径
+func (c *AliyunConfig) TokenFilePath() string {
+	if len(c.TokenFile) > 0 {
+		return c.TokenFile
+	}
+	return filepath.Join(filepath.Dir(WebConf.ConfigFile), "refresh-token")
+}
+
+//ListPage 返回列出文件夹时使用的分页和排序参数
+func (c *AliyunConfig) ListPage() model.Page {
+	return model.Page{
+		Limit:          c.ListLimit,
+		OrderBy:        c.ListOrderBy,
+		OrderDirection: c.ListOrderDirection,
+	}
+}
@@ -2,5 +2,20 @@ package config
 
 type WebConfig struct {
 	ContextPath string
+	// 配置文件路径, 运行时生成的文件默认保存在同一目录下
+	ConfigFile string
+	// User-Agent 包含其中任一字符串(不区分大小写)的客户端 GET 文件时重定向到下载地址, "*" 匹配全部客户端
+	RedirectUserAgents []string
+	// User-Agent 包含其中任一字符串的客户端始终由服务器转发文件内容, 优先于 RedirectUserAgents
+	ProxyUserAgents []string
+	// 是否允许 Depth: infinity 的 PROPFIND, 不允许时返回 403 propfind-finite-depth
+	PropfindInfiniteDepth bool
+	// 保存 PROPPATCH 设置的属性的文件, 为空时只保存在内存中
+	PropertyFile string
+	// OPTIONS 返回的 DAV 头, 逗号分隔, 为空时为 "1, 2", 不启用锁时去掉 class 2
+	DavClasses string
+	// 为 true 时即使存储提供了内容校验值也只返回弱 ETag
+	WeakETag bool
 }
+
 var WebConf = &WebConfig{}
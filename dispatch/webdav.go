@@ -1 +1,114 @@
 package dispatch
+
+import (
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strings"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/method"
+	"webdav-aliyundriver/model"
+)
+
+const (
+	MethodOptions   = "OPTIONS"
+	MethodPropfind  = "PROPFIND"
+	MethodProppatch = "PROPPATCH"
+	MethodMkcol     = "MKCOL"
+	MethodGet       = "GET"
+	MethodHead      = "HEAD"
+	MethodPut       = "PUT"
+	MethodDelete    = "DELETE"
+	MethodCopy      = "COPY"
+	MethodMove      = "MOVE"
+	MethodLock      = "LOCK"
+	MethodUnlock    = "UNLOCK"
+)
+
+//Methods WebDAV 支持分发的全部方法
+var Methods = []string{
+	MethodOptions, MethodPropfind, MethodProppatch, MethodMkcol, MethodGet, MethodHead,
+	MethodPut, MethodDelete, MethodCopy, MethodMove, MethodLock, MethodUnlock,
+}
+
+//WebDAV 按请求方法将请求分发到对应的 method.IMethodExecutor
+type WebDAV struct {
+	methods map[string]method.IMethodExecutor
+}
+
+func Build() *WebDAV {
+	return &WebDAV{
+		methods: map[string]method.IMethodExecutor{},
+	}
+}
+
+//Register 注册 name 方法的处理器, name 必须是 Methods 中的方法, executor 为 nil 时取消注册
+func (d *WebDAV) Register(name string, executor method.IMethodExecutor) {
+	if !isWebDAVMethod(name) {
+		logrus.Warnf("unsupported method %s, ignored", name)
+		return
+	}
+	if executor == nil {
+		delete(d.methods, name)
+		return
+	}
+	d.methods[name] = executor
+}
+
+//Allow 返回已注册的方法, 用于 Allow 头
+func (d *WebDAV) Allow() string {
+	names := make([]string, 0, len(d.methods))
+	for name := range d.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (d *WebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, ok := stripContextPath(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	logrus.Debugf("%s %s", r.Method, r.URL.Path)
+
+	executor, ok := d.methods[r.Method]
+	if !ok {
+		w.Header().Set("Allow", d.Allow())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	transaction := model.BuildTransaction(w, r)
+	executor.Execute(transaction, w, r)
+}
+
+//stripContextPath 去除请求路径中的 ContextPath, 请求不在 ContextPath 下时返回 false
+func stripContextPath(r *http.Request) (*http.Request, bool) {
+	contextPath := strings.TrimSuffix(config.WebConf.ContextPath, "/")
+	if len(contextPath) <= 0 {
+		return r, true
+	}
+	path := r.URL.Path
+	if path != contextPath && !strings.HasPrefix(path, contextPath+"/") {
+		return nil, false
+	}
+	path = path[len(contextPath):]
+	if len(path) <= 0 {
+		path = "/"
+	}
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2, true
+}
+
+func isWebDAVMethod(name string) bool {
+	for _, m := range Methods {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
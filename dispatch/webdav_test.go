@@ -0,0 +1,53 @@
+package dispatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/method"
+	"webdav-aliyundriver/model"
+)
+
+type recordExecutor struct {
+	path string
+}
+
+func (e *recordExecutor) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	e.path = method.RelativePath(transaction.Request())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestServeHTTP(t *testing.T) {
+	config.WebConf.ContextPath = "/dav"
+	defer func() { config.WebConf.ContextPath = "" }()
+
+	executor := &recordExecutor{}
+	d := Build()
+	d.Register(MethodGet, executor)
+	d.Register(MethodPut, executor)
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(MethodGet, "/dav/a/b.txt", nil))
+	if w.Code != http.StatusNoContent || executor.path != "/a/b.txt" {
+		t.Errorf("GET: code %d, path %q", w.Code, executor.path)
+	}
+
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(MethodGet, "/dav", nil))
+	if executor.path != "/" {
+		t.Errorf("GET context root: path %q", executor.path)
+	}
+
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(MethodGet, "/davx/a", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("outside context path: code %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(MethodMkcol, "/dav/a", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT" {
+		t.Errorf("MKCOL: code %d, allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
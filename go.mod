@@ -4,5 +4,5 @@ go 1.16
 
 require (
 	github.com/fatih/structs v1.1.0 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
+	github.com/sirupsen/logrus v1.8.1
 )
@@ -2,6 +2,9 @@ package locking
 
 import "webdav-aliyundriver/model"
 
+//DepthInfinity 锁的深度为 infinity, 包括全部子资源
+const DepthInfinity int32 = -1
+
 type IResourceLocks interface {
 
 	/**
@@ -18,22 +21,23 @@ type IResourceLocks interface {
 	 *      depth
 	 * @param timeout
 	 *      Lock Duration in seconds.
-	 * @return true if the resource at path was successfully locked, false if an
-	 *  existing lock prevented this
+	 * @return the new lock with its own lock token and true if the resource at
+	 *  path was successfully locked, false if an existing lock prevented this
 	 * @throws LockFailedException
 	 */
 	Lock(transaction model.Transaction, path string, owner string,
-		exclusive bool, depth int32, timeout int32, temporary bool) bool
+		exclusive bool, depth int32, timeout int32, temporary bool) (LockedObject, bool)
 
 	/**
-	 * Unlocks all resources at "path" (and all subfolders if existing)<p/> that
-	 * have the same owner.
+	 * Removes the lock with the specified id. Other locks on the same resource,
+	 * like further shared locks, are kept.
 	 *
 	 * @param transaction
 	 * @param id
-	 *      id to the resource to unlock
+	 *      LockToken of the lock to remove
 	 * @param owner
-	 *      who wants to unlock
+	 *      who wants to unlock, empty to skip the owner check
+	 * @return true if the lock was removed
 	 */
 
 	Unlock(transaction model.Transaction, id string, owner string) bool
@@ -115,15 +119,15 @@ type IResourceLocks interface {
 	LockedObjectByID(transaction model.Transaction, id string) LockedObject
 
 	/**
-	 * Gets the LockedObject on specified path.
+	 * Gets the locks on specified path, without the locks of its parents.
 	 *
 	 * @param transaction
 	 * @param path
 	 *      Path to requested resource
-	 * @return LockedObject or null if no LockedObject on specified path exists
+	 * @return the LockedObjects, empty if the resource is not locked
 	 */
 
-	LockedObjectByPath(transaction model.Transaction, path string) LockedObject
+	LockedObjectsByPath(transaction model.Transaction, path string) []LockedObject
 
 	/**
 	 * Gets the LockedObject corresponding to specified id (locktoken).
@@ -136,13 +140,51 @@ type IResourceLocks interface {
 	TempLockedObjectByID(transaction model.Transaction, id string) LockedObject
 
 	/**
-	 * Gets the LockedObject on specified path.
+	 * Gets the temporary locks on specified path.
 	 *
 	 * @param transaction
 	 * @param path
 	 *      Path to requested resource
-	 * @return LockedObject or null if no LockedObject on specified path exists
+	 * @return the LockedObjects, empty if the resource is not locked
+	 */
+
+	TempLockedObjectsByPath(transaction model.Transaction, path string) []LockedObject
+
+	/**
+	 * Moves the locks on "path" and all its children to "destinationPath",
+	 * used after the resource itself was moved.
+	 *
+	 * @param transaction
+	 * @param path
+	 *      the old path of the moved resource
+	 * @param destinationPath
+	 *      the new path of the moved resource
+	 */
+
+	Move(transaction model.Transaction, path string, destinationPath string)
+
+	/**
+	 * Removes the locks on "path" and all its children, used after the
+	 * resource itself was deleted.
+	 *
+	 * @param transaction
+	 * @param path
+	 *      the path of the deleted resource
+	 */
+
+	RemoveLocks(transaction model.Transaction, path string)
+
+	/**
+	 * Resets the timeout of the lock with the specified id.
+	 *
+	 * @param transaction
+	 * @param id
+	 *      LockToken of the lock to refresh
+	 * @param timeout
+	 *      new Lock Duration in seconds.
+	 * @return the refreshed LockedObject or an empty LockedObject if no such
+	 *  lock exists
 	 */
 
-	TempLockedObjectByPath(transaction model.Transaction, path string) LockedObject
+	Refresh(transaction model.Transaction, id string, timeout int32) LockedObject
 }
@@ -4,182 +4,163 @@ import (
 	"webdav-aliyundriver/util"
 )
 
+//LockedObject 锁树中的一个节点或者节点上的一个锁
+//节点只用于连接上下级节点, 节点上的锁保存在 Locks 中: 一个排他锁或者多个共享锁, 每个锁有自己的 lock token 和过期时间
 type LockedObject struct {
 	ResourceLock *ResourceLocks
 	Path         string
-	Id           string
-	LockDepth    int32
-	ExpireAt     int64
-	Owner        []string
-	Children     []LockedObject
-	Parent       *LockedObject
-	Exclusive    bool
-	Type         string
+	// lock token, 只有锁才有
+	Id        string
+	LockDepth int32
+	// 过期时间, unix 秒, 0 表示不过期
+	ExpireAt  int64
+	Owner     string
+	Locks     []*LockedObject
+	Children  []*LockedObject
+	Parent    *LockedObject
+	Exclusive bool
+	// 客户端的锁为 write, 处理请求期间的临时锁为 read
+	Type string
+	// 锁所在的节点
+	node *LockedObject
 }
 
-func (o LockedObject) CheckLocks(exclusive bool, depth int32) bool {
-	if o.CheckParents(exclusive) && o.CheckChildren(exclusive, depth) {
-		return true
+//CheckLocks 检查在该节点上加锁是否与已有的锁冲突
+func (o *LockedObject) CheckLocks(exclusive bool, depth int32) bool {
+	return o.CheckParents(exclusive) && o.CheckChildren(exclusive, depth)
+}
+
+//CheckParents 检查自身和上级资源上的锁, 上级资源的锁深度不为 0 时才覆盖自身
+func (o *LockedObject) CheckParents(exclusive bool) bool {
+	if o.conflicts(exclusive, false) {
+		return false
 	}
-	return false
+	return o.checkAncestors(exclusive)
 }
 
-//CheckParents 通过删除最后一个'/'和，从给定路径创建父路径
-func (o LockedObject) CheckParents(exclusive bool) bool {
-	if o.Path == "/" {
+//CheckChildren 检查自身和 depth 范围内子资源上的锁
+func (o *LockedObject) CheckChildren(exclusive bool, depth int32) bool {
+	if o.conflicts(exclusive, false) {
+		return false
+	}
+	if depth == 0 {
 		return true
-	} else {
-		if o.Owner == nil {
-			// no owner, checking parents
-			return o.Parent != nil && o.Parent.CheckParents(exclusive)
-		} else {
-			// there already is a owner
-			return !(o.Exclusive || exclusive) && o.Parent.CheckParents(exclusive)
+	}
+	for _, child := range o.Children {
+		if !child.CheckChildren(exclusive, depth-1) {
+			return false
 		}
 	}
+	return true
 }
 
-//CheckChildren helper of checkLocks(). looks if the children are locked
-func (o LockedObject) CheckChildren(exclusive bool, depth int32) bool {
-	if o.Children == nil {
-		// a file
-
-		return o.Owner == nil || !(o.Exclusive || exclusive)
-	} else {
-		// a folder
-
-		if o.Owner == nil {
-			// no owner, checking children
-			if depth != 0 {
-				canLock := true
-				limit := len(o.Children)
-				for i := 0; i < limit; i++ {
-					if !o.Children[i].CheckChildren(exclusive, depth-1) {
-						canLock = false
-					}
-				}
-
-				return canLock
-			} else {
-				// depth == 0 -> we don't care for children
-				return true
-			}
-		} else {
-			// there already is a owner
-			return !(o.Exclusive || exclusive)
+//checkAncestors 检查上级资源上覆盖子资源的锁
+func (o *LockedObject) checkAncestors(exclusive bool) bool {
+	for parent := o.Parent; parent != nil; parent = parent.Parent {
+		if parent.conflicts(exclusive, true) {
+			return false
 		}
 	}
+	return true
 }
 
-func (o LockedObject) addLockedObjectOwner(owner string) bool {
-	if o.Owner == nil {
-		o.Owner = []string{}
-	} else {
-
-		size := len(o.Owner)
-		var newLockObjectOwner []string
-
-		// check if the owner is already here (that should actually not
-		// happen)
-		for i := 0; i < size; i++ {
-			if o.Owner[i] == owner {
-				return false
-			}
+//conflicts 节点上已有锁时, 新锁或者已有的锁是排他锁就冲突, covering 为 true 时只检查深度不为 0 的锁
+func (o *LockedObject) conflicts(exclusive bool, covering bool) bool {
+	for _, lock := range o.Locks {
+		if (!covering || lock.LockDepth != 0) && (lock.Exclusive || exclusive) {
+			return true
 		}
-
-		//  System.arraycopy(_owner, 0, newLockObjectOwner, 0, size);
-		copy(o.Owner, newLockObjectOwner)
-		o.Owner = newLockObjectOwner
 	}
-
-	o.Owner[len(o.Owner)-1] = owner
-	return true
+	return false
 }
 
-func (o LockedObject) RemoveTempLockedObject() {
-	if &o != &o.ResourceLock.TempRoot {
-		// removing from tree
-		if o.Parent != nil && o.Parent.Children != nil {
-			size := len(o.Parent.Children)
-			for i := 0; i < size; i++ {
-				if &o.Parent.Children[i] == &o {
-
-					newChildren := []LockedObject{}
-					for i2 := 0; i2 < (size - 1); i2++ {
-						if i2 < i {
-							newChildren[i2] = o.Parent.Children[i2]
-						} else {
-							newChildren[i2] = o.Parent.Children[i2+1]
-						}
-					}
-					if len(newChildren) != 0 {
-						o.Parent.Children = newChildren
-					} else {
-						o.Parent.Children = nil
-					}
-					break
-				}
-			}
+//Locked 节点上是否有锁
+func (o *LockedObject) Locked() bool {
+	return len(o.Locks) > 0
+}
 
-			// removing from hashtable
-			delete(o.ResourceLock.TempLocksById, o.Id)
-			delete(o.ResourceLock.TempLocks, o.Path)
+//addLock 在节点上添加一个锁并登记 lock token
+func (o *LockedObject) addLock(owner string, exclusive bool, depth int32, expireAt int64) *LockedObject {
+	lock := &LockedObject{
+		ResourceLock: o.ResourceLock,
+		Path:         o.Path,
+		Id:           util.RandomUUID(),
+		LockDepth:    depth,
+		ExpireAt:     expireAt,
+		Owner:        owner,
+		Exclusive:    exclusive,
+		Type:         o.Type,
+		node:         o,
+	}
+	o.Locks = append(o.Locks, lock)
+	_, locksById := o.ResourceLock.tree(o.temporary())
+	locksById[lock.Id] = lock
+	return lock
+}
 
-			// now the garbage collector has some work to do
+//RemoveLock 删除锁和它的 lock token, 节点变为空时从锁树中删除
+func (o *LockedObject) RemoveLock() {
+	node := o.node
+	for i, lock := range node.Locks {
+		if lock == o {
+			node.Locks = append(node.Locks[:i:i], node.Locks[i+1:]...)
+			break
 		}
 	}
+	_, locksById := node.ResourceLock.tree(node.temporary())
+	delete(locksById, o.Id)
+	node.RemoveLockedObject()
 }
 
-func (o LockedObject) RemoveLockedObjectOwner(owner string) {
-	if (this != _resourceLocks._tempRoot) {
-		// removing from tree
-		if (_parent != null && _parent._children != null) {
-			int size = _parent._children.length;
-			for (int i = 0; i < size; i++) {
-				if (_parent._children[i].equals(this)) {
-					LockedObject[] newChildren = new LockedObject[size - 1];
-					for (int i2 = 0; i2 < (size - 1); i2++) {
-						if (i2 < i) {
-							newChildren[i2] = _parent._children[i2];
-						} else {
-							newChildren[i2] = _parent._children[i2 + 1];
-						}
-					}
-					if (newChildren.length != 0) {
-						_parent._children = newChildren;
-					} else {
-						_parent._children = null;
-					}
-					break;
-				}
-			}
-
-			// removing from hashtable
-			_resourceLocks._tempLocksByID.remove(getID());
-			_resourceLocks._tempLocks.remove(getPath());
+//RemoveLockedObject 从锁树中删除没有锁也没有子节点的节点, 并向上删除同样变为空的上级节点
+func (o *LockedObject) RemoveLockedObject() {
+	for node := o; node.Parent != nil && !node.Locked() && len(node.Children) <= 0; node = node.Parent {
+		node.Parent.removeChild(node)
+		locks, _ := node.ResourceLock.tree(node.temporary())
+		delete(locks, node.Path)
+	}
+}
 
-			// now the garbage collector has some work to do
+func (o *LockedObject) removeChild(child *LockedObject) {
+	for i, c := range o.Children {
+		if c == child {
+			o.Children = append(o.Children[:i:i], o.Children[i+1:]...)
+			return
 		}
 	}
 }
 
-func (o LockedObject) RemoveLockedObject() {
-
+func (o *LockedObject) temporary() bool {
+	return o.Type == TypeRead
+}
 
+//snapshot 返回锁的不与锁树共享数据的副本
+func (o *LockedObject) snapshot() LockedObject {
+	if o == nil {
+		return LockedObject{}
+	}
+	return LockedObject{
+		Path:      o.Path,
+		Id:        o.Id,
+		LockDepth: o.LockDepth,
+		ExpireAt:  o.ExpireAt,
+		Owner:     o.Owner,
+		Exclusive: o.Exclusive,
+		Type:      o.Type,
+	}
 }
 
-func CreateLockedObject(resLocks ResourceLocks, path string, temporary bool) LockedObject {
-	lockedObject := LockedObject{
+//CreateLockedObject 创建节点并登记到 resLocks 中对应的锁树, 不连接上级节点
+func CreateLockedObject(resLocks *ResourceLocks, path string, temporary bool) *LockedObject {
+	lockedObject := &LockedObject{
 		Path:         path,
-		Id:           util.NextIdStr(),
-		ResourceLock: &resLocks,
+		ResourceLock: resLocks,
+		Type:         TypeWrite,
 	}
 	if temporary {
-		resLocks.Locks[path] = lockedObject
-		resLocks.LocksById[lockedObject.Id] = lockedObject
-	} else {
-		resLocks.TempLocks[path] = lockedObject
-		resLocks.TempLocksById[lockedObject.Id] = lockedObject
+		lockedObject.Type = TypeRead
 	}
+	locks, _ := resLocks.tree(temporary)
+	locks[path] = lockedObject
 	return lockedObject
 }
@@ -0,0 +1,80 @@
+package locking
+
+import "testing"
+
+//tree 生成 /a/b/c 的锁树, 在 locked 上加锁, 返回各路径的节点
+func tree(locked string, exclusive bool, depth int32) map[string]*LockedObject {
+	r := Build()
+	nodes := map[string]*LockedObject{"/": r.Root}
+	for _, p := range []string{"/a", "/a/b", "/a/b/c"} {
+		nodes[p] = r.generate(p, false)
+	}
+	nodes[locked].addLock("owner", exclusive, depth, 0)
+	return nodes
+}
+
+func TestCheckParents(t *testing.T) {
+	tests := []struct {
+		locked        string
+		exclusive     bool
+		depth         int32
+		check         string
+		wantShared    bool
+		wantExclusive bool
+	}{
+		// 上级的 infinity 锁覆盖子资源
+		{"/a", true, DepthInfinity, "/a/b/c", false, false},
+		{"/", true, DepthInfinity, "/a/b", false, false},
+		{"/a", false, DepthInfinity, "/a/b", true, false},
+		// 上级深度为 0 的锁不覆盖子资源
+		{"/a", true, 0, "/a/b", true, true},
+		// 同一资源上的锁
+		{"/a/b", false, 0, "/a/b", true, false},
+		{"/a/b", true, 0, "/a/b", false, false},
+		// 子资源的锁不影响 CheckParents
+		{"/a/b/c", true, 0, "/a", true, true},
+	}
+	for _, test := range tests {
+		nodes := tree(test.locked, test.exclusive, test.depth)
+		if got := nodes[test.check].CheckParents(false); got != test.wantShared {
+			t.Errorf("%s locked (exclusive %t, depth %d), shared check %s: %t",
+				test.locked, test.exclusive, test.depth, test.check, got)
+		}
+		if got := nodes[test.check].CheckParents(true); got != test.wantExclusive {
+			t.Errorf("%s locked (exclusive %t, depth %d), exclusive check %s: %t",
+				test.locked, test.exclusive, test.depth, test.check, got)
+		}
+	}
+}
+
+func TestCheckChildren(t *testing.T) {
+	tests := []struct {
+		locked        string
+		exclusive     bool
+		check         string
+		depth         int32
+		wantShared    bool
+		wantExclusive bool
+	}{
+		{"/a/b/c", true, "/a", DepthInfinity, false, false},
+		{"/a/b/c", false, "/a", DepthInfinity, true, false},
+		{"/a/b/c", true, "/a", 0, true, true},
+		// depth 1 只检查直接子资源
+		{"/a/b/c", true, "/a", 1, true, true},
+		{"/a/b", true, "/a", 1, false, false},
+		{"/a/b", false, "/a/b", 0, true, false},
+		// 上级资源的锁不影响 CheckChildren
+		{"/a", true, "/a/b", DepthInfinity, true, true},
+	}
+	for _, test := range tests {
+		nodes := tree(test.locked, test.exclusive, 0)
+		if got := nodes[test.check].CheckChildren(false, test.depth); got != test.wantShared {
+			t.Errorf("%s locked (exclusive %t), shared check %s depth %d: %t",
+				test.locked, test.exclusive, test.check, test.depth, got)
+		}
+		if got := nodes[test.check].CheckChildren(true, test.depth); got != test.wantExclusive {
+			t.Errorf("%s locked (exclusive %t), exclusive check %s depth %d: %t",
+				test.locked, test.exclusive, test.check, test.depth, got)
+		}
+	}
+}
@@ -8,205 +8,277 @@ import (
 	"webdav-aliyundriver/model"
 )
 
+const (
+	// 客户端通过 LOCK 获得的锁
+	TypeWrite = "write"
+	// 处理请求期间使用的临时锁
+	TypeRead = "read"
+)
+
+//ResourceLocks 基于内存的 IResourceLocks 实现, 客户端的锁和临时锁分别保存在两棵锁树中, 加锁时两棵树都要检查
+//Locks 和 TempLocks 按路径保存节点, LocksById 和 TempLocksById 按 lock token 保存锁
 type ResourceLocks struct {
-	// 100000
-	CleanupLimit int32
-	// 0
-	CleanupCounter int32
-	Locks          map[string]LockedObject
-	LocksById      map[string]LockedObject
-	TempLocks      map[string]LockedObject
-	TempLocksById  map[string]LockedObject
-	Root           LockedObject
-	TempRoot       LockedObject
-	// true
-	Temporary bool
-}
-
-func Build() ResourceLocks {
-	return ResourceLocks{
-		Locks:         map[string]LockedObject{},
-		LocksById:     map[string]LockedObject{},
-		TempLocks:     map[string]LockedObject{},
-		TempLocksById: map[string]LockedObject{},
-		Temporary:     true,
-		CleanupLimit:  100000,
-	}
-}
-
-func (r ResourceLocks) Lock(transaction model.Transaction, path string, owner string,
-	exclusive bool, depth int32, timeout int32, temporary bool) bool {
-	var lo LockedObject
-	if temporary {
-		lo = r.GenerateTempLockedObjects(transaction, path)
-		lo.Type = "read"
-	} else {
-		lo = r.GenerateLockedObjects(transaction, path)
-		lo.Type = "write"
-	}
-	if lo.CheckLocks(exclusive, depth) {
-
-		lo.Exclusive = exclusive
-		lo.LockDepth = depth
-		lo.ExpireAt = time.Now().Unix() + int64(1000*timeout)
-		if lo.Parent != nil {
-			lo.Parent.ExpireAt = lo.ExpireAt
-			if lo.Parent == &r.Root {
-				rootLo := r.LockedObjectByPath(transaction, r.Root.Path)
-				rootLo.ExpireAt = lo.ExpireAt
-			} else if lo.Parent == &r.TempRoot {
-				tempRootLo := r.TempLockedObjectByPath(transaction, r.TempRoot.Path)
-				tempRootLo.ExpireAt = lo.ExpireAt
-			}
-		}
-		if lo.addLockedObjectOwner(owner) {
-			return true
-		} else {
-			logrus.Errorf("Couldn't set owner \"" + owner + "\" to resource at '" + path + "'")
-			return false
-		}
-	} else {
-		// can not lock
-		logrus.Errorf("Lock resource at " + path + " failed because" + "\na parent or child resource is currently locked")
-		return false
-	}
+	mu            sync.Mutex
+	Locks         map[string]*LockedObject
+	LocksById     map[string]*LockedObject
+	TempLocks     map[string]*LockedObject
+	TempLocksById map[string]*LockedObject
+	Root          *LockedObject
+	TempRoot      *LockedObject
 }
 
-func (r ResourceLocks) Unlock(transaction model.Transaction, id string, owner string) bool {
-	if lock, ok := r.LocksById[id]; ok {
-		path := lock.Path
-		if lo, ok := r.LocksById[id]; ok {
-			lo.RemoveLockedObjectOwner(owner)
-			if lo.Children == nil && lo.Owner == nil {
-				lo.RemoveLockedObject()
-			}
+func Build() *ResourceLocks {
+	r := &ResourceLocks{
+		Locks:         map[string]*LockedObject{},
+		LocksById:     map[string]*LockedObject{},
+		TempLocks:     map[string]*LockedObject{},
+		TempLocksById: map[string]*LockedObject{},
+	}
+	r.Root = CreateLockedObject(r, "/", false)
+	r.TempRoot = CreateLockedObject(r, "/", true)
+	return r
+}
 
-		} else {
-			// there is no lock at that path. someone tried to unlock it
-			// anyway. could point to a problem
-			logrus.Trace("net.sf.webdav.locking.ResourceLocks.unlock(): no lock for path " + path)
-			return false
-		}
+//Lock timeout 为秒, 小于等于 0 时锁不过期, 每次成功加锁都得到一个新的锁
+func (r *ResourceLocks) Lock(transaction model.Transaction, path string, owner string,
+	exclusive bool, depth int32, timeout int32, temporary bool) (LockedObject, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path = cleanPath(path)
+	now := time.Now().Unix()
+	r.expire(now, false)
+	r.expire(now, true)
+	if !r.check(path, exclusive, depth, false) || !r.check(path, exclusive, depth, true) {
+		logrus.Debugf("lock %s failed: a parent or child resource is currently locked", path)
+		return LockedObject{}, false
+	}
 
-		if r.CleanupCounter > r.CleanupLimit {
-			r.CleanupCounter = 0
-			r.CleanLockedObjects(transaction, r.Root, !r.Temporary)
-		}
+	expireAt := int64(0)
+	if timeout > 0 {
+		expireAt = now + int64(timeout)
 	}
-	r.CheckTimeouts(transaction, !r.Temporary)
+	return r.generate(path, temporary).addLock(owner, exclusive, depth, expireAt).snapshot(), true
 }
 
-func (r ResourceLocks) UnlockTemporaryLockedObjects(transaction model.Transaction, path string, owner string) {
-	panic("implement me")
+//Unlock 解除锁 id, owner 不为空时锁必须属于 owner, 锁不存在或者不属于 owner 时返回 false
+func (r *ResourceLocks) Unlock(transaction model.Transaction, id string, owner string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now().Unix(), false)
+	lo, ok := r.LocksById[id]
+	if !ok || (len(owner) > 0 && lo.Owner != owner) {
+		logrus.Debugf("unlock %s by %q failed: no such lock", id, owner)
+		return false
+	}
+	lo.RemoveLock()
+	return true
 }
 
-func (r ResourceLocks) CheckTimeouts(transaction model.Transaction, temporary bool) {
-	panic("implement me")
+//UnlockTemporaryLockedObjects 解除 path 上属于 owner 的临时锁
+func (r *ResourceLocks) UnlockTemporaryLockedObjects(transaction model.Transaction, path string, owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var owned []*LockedObject
+	if node, ok := r.TempLocks[cleanPath(path)]; ok {
+		for _, lo := range node.Locks {
+			if lo.Owner == owner {
+				owned = append(owned, lo)
+			}
+		}
+	}
+	if len(owned) <= 0 {
+		logrus.Warnf("unlock temporary lock on %s by %q failed: no such lock", path, owner)
+		return
+	}
+	for _, lo := range owned {
+		lo.RemoveLock()
+	}
 }
 
-func (r ResourceLocks) ExclusiveLock(transaction model.Transaction, path string, owner string, depth, timeout int32) bool {
-	panic("implement me")
+//CheckTimeouts 删除已经过期的锁
+func (r *ResourceLocks) CheckTimeouts(transaction model.Transaction, temporary bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now().Unix(), temporary)
 }
 
-func (r ResourceLocks) SharedLock(transaction model.Transaction, path string, owner string, depth int32, timeout int32) bool {
-	panic("implement me")
+func (r *ResourceLocks) ExclusiveLock(transaction model.Transaction, path string, owner string, depth, timeout int32) bool {
+	_, ok := r.Lock(transaction, path, owner, true, depth, timeout, false)
+	return ok
 }
 
-func (r ResourceLocks) LockedObjectByID(transaction model.Transaction, id string) LockedObject {
-	panic("implement me")
+func (r *ResourceLocks) SharedLock(transaction model.Transaction, path string, owner string, depth int32, timeout int32) bool {
+	_, ok := r.Lock(transaction, path, owner, false, depth, timeout, false)
+	return ok
 }
 
-func (r ResourceLocks) LockedObjectByPath(transaction model.Transaction, path string) LockedObject {
-	panic("implement me")
+//LockedObjectByID 返回锁的副本, 锁不存在或者已经过期时返回零值
+func (r *ResourceLocks) LockedObjectByID(transaction model.Transaction, id string) LockedObject {
+	return r.findById(id, false)
 }
 
-func (r ResourceLocks) TempLockedObjectByID(transaction model.Transaction, id string) LockedObject {
-	panic("implement me")
+//LockedObjectsByPath 返回 path 上全部锁的副本, 不包括上级资源的锁
+func (r *ResourceLocks) LockedObjectsByPath(transaction model.Transaction, path string) []LockedObject {
+	return r.findByPath(cleanPath(path), false)
 }
 
-func (r ResourceLocks) TempLockedObjectByPath(transaction model.Transaction, path string) LockedObject {
-	panic("implement me")
+func (r *ResourceLocks) TempLockedObjectByID(transaction model.Transaction, id string) LockedObject {
+	return r.findById(id, true)
 }
 
-//GenerateTempLockedObjects 为路径及其父资源生成临时LockedObjects
-//文件夹。如果LockedObjects已经存在，不创建新的LockedObjects
+func (r *ResourceLocks) TempLockedObjectsByPath(transaction model.Transaction, path string) []LockedObject {
+	return r.findByPath(cleanPath(path), true)
+}
 
-func (r ResourceLocks) GenerateLockedObjects(transaction model.Transaction, path string) LockedObject {
+//Move 将 path 及其子路径上的锁转移到 destinationPath 下, destinationPath 上原有的锁被删除
+func (r *ResourceLocks) Move(transaction model.Transaction, path string, destinationPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path = cleanPath(path)
+	destinationPath = cleanPath(destinationPath)
+	moved := r.detach(path)
+	r.detach(destinationPath)
+	for _, lo := range moved {
+		target := r.generate(destinationPath+strings.TrimPrefix(lo.Path, path), false)
+		lo.Path = target.Path
+		lo.node = target
+		target.Locks = append(target.Locks, lo)
+		r.LocksById[lo.Id] = lo
+	}
+}
 
-	if object, ok := r.Locks[path]; ok {
-		// there is already a LockedObject on the specified path
+//RemoveLocks 删除 path 及其子路径上客户端的锁
+func (r *ResourceLocks) RemoveLocks(transaction model.Transaction, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detach(cleanPath(path))
+}
 
-		return object
+//Refresh 重新设置锁 id 的超时时间, 锁不存在或者已经过期时返回零值
+func (r *ResourceLocks) Refresh(transaction model.Transaction, id string, timeout int32) LockedObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().Unix()
+	r.expire(now, false)
+	lo, ok := r.LocksById[id]
+	if !ok {
+		return LockedObject{}
+	}
+	lo.ExpireAt = 0
+	if timeout > 0 {
+		lo.ExpireAt = now + int64(timeout)
+	}
+	return lo.snapshot()
+}
 
-	} else {
-		returnObject := CreateLockedObject(r, path, !r.Temporary)
-		parentPath := ParentPath(path)
-		if len(parentPath) > 0 {
-			parentLockedObject := r.GenerateLockedObjects(transaction, parentPath)
-			parentLockedObject.Children = append(parentLockedObject.Children, returnObject)
-			returnObject.Parent = &parentLockedObject
+//detach 删除 path 及其子路径上客户端的锁, 返回删除的锁
+func (r *ResourceLocks) detach(path string) []*LockedObject {
+	var detached []*LockedObject
+	for _, lo := range r.LocksById {
+		if lo.Path == path || strings.HasPrefix(lo.Path, path+"/") || path == "/" {
+			detached = append(detached, lo)
 		}
-		return returnObject
 	}
+	for _, lo := range detached {
+		lo.RemoveLock()
+	}
+	return detached
+}
+
+//GenerateLockedObjects 为 path 及其上级资源生成客户端锁树中的节点, 已经存在的节点直接返回
+func (r *ResourceLocks) GenerateLockedObjects(transaction model.Transaction, path string) *LockedObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generate(cleanPath(path), false)
+}
 
+//GenerateTempLockedObjects 为 path 及其上级资源生成临时锁树中的节点, 已经存在的节点直接返回
+func (r *ResourceLocks) GenerateTempLockedObjects(transaction model.Transaction, path string) *LockedObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generate(cleanPath(path), true)
 }
-func (r ResourceLocks) GenerateTempLockedObjects(transaction model.Transaction, path string) LockedObject {
-	if lo, ok := r.TempLocks[path]; ok {
+
+func (r *ResourceLocks) generate(path string, temporary bool) *LockedObject {
+	locks, _ := r.tree(temporary)
+	if lo, ok := locks[path]; ok {
 		return lo
-	} else {
-		lockedObject := CreateLockedObject(r, path, r.Temporary)
-		parentPath := ParentPath(path)
-		if len(parentPath) > 0 {
-			parentLockedObject := r.GenerateTempLockedObjects(transaction, parentPath)
-			parentLockedObject.Children = append(parentLockedObject.Children, lockedObject)
-			lockedObject.Parent = &parentLockedObject
+	}
+	parent := r.generate(ParentPath(path), temporary)
+	lo := CreateLockedObject(r, path, temporary)
+	lo.Parent = parent
+	parent.Children = append(parent.Children, lo)
+	return lo
+}
+
+//check 检查 path 上能否加锁, 节点不存在时说明子资源没有锁, 只检查最近的上级节点
+func (r *ResourceLocks) check(path string, exclusive bool, depth int32, temporary bool) bool {
+	locks, _ := r.tree(temporary)
+	if lo, ok := locks[path]; ok {
+		return lo.CheckLocks(exclusive, depth)
+	}
+	for p := ParentPath(path); ; p = ParentPath(p) {
+		if lo, ok := locks[p]; ok {
+			return !lo.conflicts(exclusive, true) && lo.checkAncestors(exclusive)
 		}
-		return lockedObject
 	}
 }
 
-func (r ResourceLocks) CleanLockedObjects(transaction model.Transaction, lo LockedObject, temporary bool)bool {
+func (r *ResourceLocks) findById(id string, temporary bool) LockedObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now().Unix(), temporary)
+	_, locksById := r.tree(temporary)
+	return locksById[id].snapshot()
+}
 
-	if lo.Children == nil {
-		if lo.Owner == nil {
-			if temporary {
-				lo.RemoveTempLockedObject()
-			} else {
-				lo.RemoveLockedObject()
-			}
+func (r *ResourceLocks) findByPath(path string, temporary bool) []LockedObject {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now().Unix(), temporary)
+	locks, _ := r.tree(temporary)
+	node, ok := locks[path]
+	if !ok {
+		return nil
+	}
+	snapshots := make([]LockedObject, 0, len(node.Locks))
+	for _, lo := range node.Locks {
+		snapshots = append(snapshots, lo.snapshot())
+	}
+	return snapshots
+}
 
-			return true
-		} else {
-			return false
-		}
-	} else {
-		 canDelete := true
-		 limit := len(lo.Children)
-		for i := 0; i < limit; i++ {
-			if !r.CleanLockedObjects(transaction, lo.Children[i], temporary) {
-				canDelete = false
-			} else {
-				// because the deleting shifts the array
-				i--
-				limit--
-			}
+//expire 删除 ExpireAt 不晚于 now 的锁, 调用方持有 mu
+func (r *ResourceLocks) expire(now int64, temporary bool) {
+	_, locksById := r.tree(temporary)
+	var expired []*LockedObject
+	for _, lo := range locksById {
+		if lo.ExpireAt > 0 && lo.ExpireAt <= now {
+			expired = append(expired, lo)
 		}
+	}
+	for _, lo := range expired {
+		logrus.Debugf("lock %s on %s expired", lo.Id, lo.Path)
+		lo.RemoveLock()
+	}
+}
 
-		if canDelete {
-			if lo.Owner == nil {
-				if temporary {
-					lo.RemoveTempLockedObject()
-				} else {
-					lo.RemoveLockedObject()
-				}
-				return true
-			} else {
-				return false
-			}
-		} else {
-			return false
-		}
+func (r *ResourceLocks) tree(temporary bool) (map[string]*LockedObject, map[string]*LockedObject) {
+	if temporary {
+		return r.TempLocks, r.TempLocksById
+	}
+	return r.Locks, r.LocksById
+}
+
+//cleanPath 锁树中的路径以 / 开头且不以 / 结尾
+func cleanPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if path == "/" {
+		return path
 	}
+	return strings.TrimSuffix(path, "/")
 }
 
 // ParentPath 通过删除最后一个'/'及其之后的所有内容，从给定路径创建父路径
@@ -0,0 +1,155 @@
+package locking
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"webdav-aliyundriver/model"
+)
+
+func TestResourceLocks(t *testing.T) {
+	var transaction model.Transaction
+	r := Build()
+
+	lo, ok := r.Lock(transaction, "/a", "alice", true, DepthInfinity, 60, false)
+	if !ok || lo.Id == "" || !lo.Exclusive || lo.LockDepth != DepthInfinity || lo.Owner != "alice" || lo.Path != "/a" {
+		t.Fatalf("lock on /a: %+v", lo)
+	}
+	if got := r.LockedObjectsByPath(transaction, "/a/"); len(got) != 1 || !reflect.DeepEqual(got[0], lo) {
+		t.Errorf("locks on /a: %+v", got)
+	}
+	if got := r.LockedObjectByID(transaction, lo.Id); !reflect.DeepEqual(got, lo) {
+		t.Errorf("lock by id: %+v", got)
+	}
+	if r.SharedLock(transaction, "/a/b", "bob", 0, 60) {
+		t.Error("locked a child of an exclusive infinity lock")
+	}
+	if r.SharedLock(transaction, "/", "bob", DepthInfinity, 60) {
+		t.Error("locked a parent of an exclusive lock with depth infinity")
+	}
+	if !r.SharedLock(transaction, "/", "bob", 0, 60) {
+		t.Error("depth 0 lock on the parent of a locked resource failed")
+	}
+	// 临时锁也要检查客户端的锁
+	if _, ok := r.Lock(transaction, "/a", "request", true, DepthInfinity, 10, true); ok {
+		t.Error("temporary lock ignored the lock on /a")
+	}
+
+	if r.Unlock(transaction, lo.Id, "bob") {
+		t.Error("unlocked by someone else")
+	}
+	if !r.Unlock(transaction, lo.Id, "alice") {
+		t.Error("unlock failed")
+	}
+	if got := r.LockedObjectsByPath(transaction, "/a"); len(got) > 0 {
+		t.Errorf("lock still present: %+v", got)
+	}
+	if _, ok := r.Locks["/a"]; ok {
+		t.Error("empty node not removed")
+	}
+}
+
+func TestResourceLocksShared(t *testing.T) {
+	var transaction model.Transaction
+	r := Build()
+
+	// 每个共享锁有自己的 lock token 和过期时间
+	alice, ok := r.Lock(transaction, "/s", "alice", false, 0, 60, false)
+	if !ok {
+		t.Fatal("shared lock failed")
+	}
+	bob, ok := r.Lock(transaction, "/s", "bob", false, 0, 3600, false)
+	if !ok || bob.Id == alice.Id || bob.ExpireAt == alice.ExpireAt {
+		t.Fatalf("second shared lock %+v, first %+v", bob, alice)
+	}
+	if _, ok := r.Lock(transaction, "/s", "alice", false, 0, 60, false); !ok {
+		t.Error("second shared lock of the same owner failed")
+	}
+	if r.ExclusiveLock(transaction, "/s", "carol", 0, 60) {
+		t.Error("exclusive lock on a shared lock")
+	}
+	if got := r.LockedObjectsByPath(transaction, "/s"); len(got) != 3 {
+		t.Fatalf("shared locks %+v", got)
+	}
+
+	if !r.Unlock(transaction, bob.Id, "") {
+		t.Fatal("unlock failed")
+	}
+	if got := r.LockedObjectByID(transaction, alice.Id); !reflect.DeepEqual(got, alice) {
+		t.Errorf("lock of alice after bob unlocked: %+v", got)
+	}
+	if got := r.LockedObjectByID(transaction, bob.Id); got.Id != "" {
+		t.Errorf("unlocked lock %+v", got)
+	}
+	if refreshed := r.Refresh(transaction, alice.Id, 600); refreshed.Id != alice.Id || refreshed.ExpireAt <= alice.ExpireAt {
+		t.Errorf("refreshed lock %+v", refreshed)
+	}
+}
+
+func TestResourceLocksTimeout(t *testing.T) {
+	var transaction model.Transaction
+	r := Build()
+	lo, ok := r.Lock(transaction, "/a", "alice", true, 0, 60, false)
+	if !ok {
+		t.Fatal("lock failed")
+	}
+	r.LocksById[lo.Id].ExpireAt = time.Now().Unix() - 1
+	if got := r.LockedObjectsByPath(transaction, "/a"); len(got) > 0 {
+		t.Errorf("expired lock %+v", got)
+	}
+	if !r.ExclusiveLock(transaction, "/a", "bob", 0, 60) {
+		t.Error("lock after expiry failed")
+	}
+}
+
+func TestResourceLocksTemporary(t *testing.T) {
+	var transaction model.Transaction
+	r := Build()
+	if _, ok := r.Lock(transaction, "/a", "request1", true, DepthInfinity, 10, true); !ok {
+		t.Fatal("temporary lock failed")
+	}
+	if _, ok := r.Lock(transaction, "/a/b", "request2", true, 0, 10, true); ok {
+		t.Error("second temporary lock succeeded")
+	}
+	if r.ExclusiveLock(transaction, "/a/b", "alice", 0, 60) {
+		t.Error("lock during a temporary lock succeeded")
+	}
+	if got := r.TempLockedObjectsByPath(transaction, "/a"); len(got) != 1 || got[0].Type != TypeRead {
+		t.Errorf("temporary lock %+v", got)
+	}
+	r.UnlockTemporaryLockedObjects(transaction, "/a", "request1")
+	if !r.ExclusiveLock(transaction, "/a/b", "alice", 0, 60) {
+		t.Error("lock after the temporary lock was released failed")
+	}
+}
+
+func TestResourceLocksMove(t *testing.T) {
+	var transaction model.Transaction
+	r := Build()
+	lo, ok := r.Lock(transaction, "/a/b", "alice", true, 0, 60, false)
+	if !ok || !r.ExclusiveLock(transaction, "/c", "bob", 0, 60) {
+		t.Fatal("lock failed")
+	}
+	r.Move(transaction, "/a", "/c")
+	if got := r.LockedObjectsByPath(transaction, "/c/b"); len(got) != 1 || got[0].Id != lo.Id || got[0].Owner != "alice" {
+		t.Errorf("moved lock %+v", got)
+	}
+	if got := r.LockedObjectByID(transaction, lo.Id); got.Path != "/c/b" {
+		t.Errorf("moved lock by id %+v", got)
+	}
+	// 目标上原有的锁被删除
+	if got := r.LockedObjectsByPath(transaction, "/c"); len(got) > 0 {
+		t.Errorf("lock on destination %+v", got)
+	}
+	if _, ok := r.Locks["/a"]; ok {
+		t.Error("source nodes left behind")
+	}
+
+	r.RemoveLocks(transaction, "/c")
+	if got := r.LockedObjectByID(transaction, lo.Id); got.Id != "" {
+		t.Errorf("lock after RemoveLocks %+v", got)
+	}
+	if _, ok := r.Locks["/c"]; ok {
+		t.Error("nodes left behind after RemoveLocks")
+	}
+}
@@ -1,70 +1,67 @@
 package method
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
 	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
 )
 
 const (
-	Iso8601        = "yyyy-MM-dd'T'HH:mm:ss'Z'"
-	Rfc1123        = "EEE, dd MMM yyyy HH:mm:ss z"
-	DDMMYYHHMMSS   = "dd/MM/yy' 'HH:mm:ss"
+	// 时间格式, 使用前需要先转换为 UTC
+	Iso8601        = "2006-01-02T15:04:05Z"
+	Rfc1123        = "Mon, 02 Jan 2006 15:04:05 GMT"
+	DDMMYYHHMMSS   = "02/01/06 15:04:05"
 	DefaultTimeout = 3600
-	Infinity       = 3
+	// 客户端请求 Infinite 或者更长时间时使用的锁超时时间, 秒
+	MaxTimeout = 604800
+	// 处理请求期间临时锁的超时时间, 秒
+	TempTimeout = 10
+	Infinity    = 3
 )
 const (
 	DestinationKey = "Destination"
+	// lock token 的 URI 前缀
+	LockTokenPrefix = "opaquelocktoken:"
 )
 
+//ParseDestinationHeader 返回 Destination 头中相对于 ContextPath 的路径, Destination 可以是完整的 URL 或者绝对路径
+//Destination 缺失或者无效时返回 400, 不在 ContextPath 下时返回 502, 已经写入状态码时返回错误
 func ParseDestinationHeader(response http.ResponseWriter, request *http.Request) (string, error) {
-	// 获取文件路径
-	path := request.Header.Get(DestinationKey)
-	if len(path) <= 0 {
+	destination := request.Header.Get(DestinationKey)
+	if len(destination) <= 0 {
 		response.WriteHeader(http.StatusBadRequest)
-		return "", nil
+		return "", errors.New("missing Destination header")
 	}
-	// Remove url encoding from destination
-	destinationPath, err := url.PathUnescape(path)
+	// url.Parse 同时去除 url 编码
+	u, err := url.Parse(destination)
 	if err != nil {
-		destinationPath = path
-	}
-	protocolIndex := strings.Index(destinationPath, "://")
-	if protocolIndex >= 0 {
-		//如果目标URL包含协议，我们可以安全地 将“://”后的第一个“/”字符进行修剪 ://xxx -> / ://xxx/bb -> /bb
-		firstSeparator := strings.Index(destinationPath[protocolIndex+4:], "/")
-		if firstSeparator < 0 {
-			destinationPath = "/"
-		} else {
-			destinationPath = destinationPath[firstSeparator:]
-		}
-	} else {
-		hostName := request.Host
-		if len(hostName) > 0 && strings.HasPrefix(destinationPath, hostName) {
-			//直接获取路径 需要通过host对比 host:ggg  destinationPath:ggg/sss -> /sss
-			destinationPath = destinationPath[len(hostName):]
-		}
-		portIndex := strings.Index(destinationPath, ":")
-		if portIndex >= 0 {
-			destinationPath = destinationPath[portIndex:]
-		}
-		if strings.HasPrefix(destinationPath, ":") {
-			firstSeparator := strings.Index(destinationPath, "/")
-			if firstSeparator < 0 {
-				destinationPath = "/"
-			} else {
-				destinationPath = destinationPath[firstSeparator:]
-			}
+		response.WriteHeader(http.StatusBadRequest)
+		return "", err
+	}
+	destinationPath := Normalize(u.Path)
+	if len(destinationPath) <= 0 {
+		response.WriteHeader(http.StatusBadRequest)
+		return "", fmt.Errorf("invalid Destination %s", destination)
+	}
+	contextPath := strings.TrimSuffix(config.WebConf.ContextPath, "/")
+	if len(contextPath) > 0 {
+		if destinationPath != contextPath && !strings.HasPrefix(destinationPath, contextPath+"/") {
+			response.WriteHeader(http.StatusBadGateway)
+			return "", fmt.Errorf("destination %s outside of %s", destination, contextPath)
 		}
+		destinationPath = destinationPath[len(contextPath):]
 	}
-	destinationPath = Normalize(destinationPath)
-	if len(config.WebConf.ContextPath) > 0 && strings.HasPrefix(destinationPath, config.WebConf.ContextPath) {
-		destinationPath = destinationPath[len(config.WebConf.ContextPath):]
+	if len(destinationPath) <= 0 {
+		destinationPath = "/"
 	}
-	return destinationPath, nil
+	return CleanPath(destinationPath), nil
 }
 
 //Normalize 返回一个上下文相关的路径，以"/"开头，表示解析出".."和"."元素后指定路径的规范版本。
@@ -79,9 +76,13 @@ func Normalize(path string) string {
 	}
 	if strings.Index(normalized, "\\") >= 0 {
 		normalized = strings.Replace(normalized, "\\", "/", -1)
-		if strings.HasPrefix(normalized, "/") {
-			normalized = "/" + normalized
-		}
+	}
+	if !strings.HasPrefix(normalized, "/") {
+		normalized = "/" + normalized
+	}
+	// 末尾的"/."和"/.."按照中间的处理
+	if strings.HasSuffix(normalized, "/.") || strings.HasSuffix(normalized, "/..") {
+		normalized = normalized + "/"
 	}
 	// 解析在规范化路径中出现的"//"
 	for {
@@ -108,19 +109,20 @@ func Normalize(path string) string {
 		if index == 0 {
 			return ""
 		}
-		index2 := strings.LastIndex(normalized[index-1:], "/")
+		index2 := strings.LastIndex(normalized[:index], "/")
 		normalized = normalized[0:index2] + normalized[index+3:]
 	}
 	// 返回我们已经完成的规范化路径
 	return normalized
 }
 
+//RelativePath 返回请求资源相对于 ContextPath 的路径, ContextPath 已由 dispatch.WebDAV 去除
 func RelativePath(r *http.Request) string {
-	destinationPath := r.RequestURI
-	if len(config.WebConf.ContextPath) > 0 && strings.HasPrefix(destinationPath, config.WebConf.ContextPath) {
-		destinationPath = destinationPath[len(config.WebConf.ContextPath):]
+	path := r.URL.Path
+	if len(path) <= 0 {
+		return "/"
 	}
-	return destinationPath
+	return path
 }
 
 //ParentPath 通过删除最后一个'/'及其之后的所有内容，从给定路径创建父路径
@@ -154,12 +156,25 @@ func Depth(r *http.Request) int {
 	return depth
 }
 
+//Overwrite 读取 Overwrite 头, 只有值为 F 时返回 false
+func Overwrite(r *http.Request) bool {
+	return !strings.EqualFold(strings.TrimSpace(r.Header.Get("Overwrite")), "F")
+}
+
 func RewriteUrl(path string) string {
 	return url.PathEscape(path)
 }
 
 //ETag 获取与文件关联的ETag。
+//存储提供内容校验值时返回强 ETag, 配置了 WeakETag 时返回同样内容的弱 ETag, 没有校验值时由大小和修改时间组成弱 ETag
 func ETag(so *model.StoredObject) string {
+	if so != nil && len(so.ContentHash) > 0 {
+		etag := "\"" + so.ContentHash + "\""
+		if config.WebConf.WeakETag {
+			return "W/" + etag
+		}
+		return etag
+	}
 
 	resourceLength := ""
 	lastModified := ""
@@ -170,44 +185,81 @@ func ETag(so *model.StoredObject) string {
 	return "W/\"" + resourceLength + "-" + lastModified + "\""
 }
 
+//LockIdFromIfHeader 返回 If 头中提交的 lock id, 已去除 opaquelocktoken: 前缀, 头格式错误时返回 nil
 func LockIdFromIfHeader(req *http.Request) []string {
+	header := req.Header.Get("If")
+	if len(header) <= 0 {
+		return nil
+	}
+	ifHeader, err := ParseIfHeader(header)
+	if err != nil {
+		return nil
+	}
 	var ids []string
-	id := req.Header.Get("If")
+	for _, token := range ifHeader.Tokens() {
+		ids = append(ids, strings.TrimPrefix(token, LockTokenPrefix))
+	}
+	return ids
+}
 
-	if len(id) > 0 {
-		if strings.Index(id, ">)") == strings.LastIndex(id, ">)") {
-			id = id[strings.Index(id, "(<"):strings.Index(id, ">)")]
-			if strings.Index(id, "locktoken:") != -1 {
-				id = id[strings.Index(id, ":")+1:]
-			}
-			ids[0] = id
-		} else {
-			firstId := id[strings.Index(id, "(<"):strings.Index(id, (">)"))]
-			if strings.Index(firstId, "locktoken:") != -1 {
-				firstId = firstId[(strings.Index(firstId, ":") + 1):]
-			}
-			ids[0] = firstId
+//LockIdFromLockTokenHeader 返回 Lock-Token 头中的 lock id, 已去除 <> 和 opaquelocktoken: 前缀, 头格式错误时返回空
+func LockIdFromLockTokenHeader(req *http.Request) string {
+	id := strings.TrimSpace(req.Header.Get("Lock-Token"))
+	if !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, ">") {
+		return ""
+	}
+	return strings.TrimPrefix(id[1:len(id)-1], LockTokenPrefix)
+}
 
-			secondId := id[strings.Index(id, "(<"):strings.Index(id, ">)")]
-			if strings.Index(secondId, "locktoken:") != -1 {
-				secondId = secondId[strings.Index(secondId, ":")+1:]
+//CheckLocks 检查 path 是否被锁定, 被锁定时请求需要在 If 头中携带对应的 lock token
+//resourceLocks 为 nil 表示未启用锁
+func CheckLocks(transaction model.Transaction, r *http.Request, w http.ResponseWriter,
+	resourceLocks locking.IResourceLocks, path string) bool {
+	if resourceLocks == nil {
+		return true
+	}
+	locks := CoveringLocks(transaction, resourceLocks, path)
+	if len(locks) <= 0 {
+		return true
+	}
+	// the resource is locked, 共享锁和排他锁都需要提交其中一个锁的 lock token
+	for _, id := range LockIdFromIfHeader(r) {
+		for _, lo := range locks {
+			if lo.Id == id {
+				return true
 			}
-			ids[1] = secondId
 		}
-	} else {
-		ids = nil
 	}
-	return ids
+	return false
 }
-func LockIdFromLockTokenHeader(req *http.Request) string {
-	id := req.Header.Get("Lock-Token")
-	if len(id) > 0 {
-		id = id[strings.Index(id, ":")+1 : strings.Index(id, ">")]
+
+//CoveringLocks 返回作用于 path 的锁, 包括 path 自身的锁和上级文件夹上深度不为 0 的锁
+func CoveringLocks(transaction model.Transaction, resourceLocks locking.IResourceLocks, path string) []locking.LockedObject {
+	var locks []locking.LockedObject
+	// 规范化后向上查找一定会到达根路径, 根路径的 ParentPath 为空
+	path = model.BuildPathInfo(path).Path
+	for current := path; len(current) > 0; current = model.BuildPathInfo(current).ParentPath {
+		for _, lo := range resourceLocks.LockedObjectsByPath(transaction, current) {
+			if current == path || lo.LockDepth != 0 {
+				locks = append(locks, lo)
+			}
+		}
 	}
-	return id
+	return locks
 }
 
-func CheckLocks(transaction model.Transaction, r *http.Request, w http.ResponseWriter
-) bool {
-
-}
\ No newline at end of file
+//StoreErrorStatus 将 store.Store 返回的错误转换为 HTTP 状态码
+func StoreErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrExists):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, store.ErrNotSupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
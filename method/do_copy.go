@@ -0,0 +1,144 @@
+package method
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"path"
+	"strings"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoCopy 复制资源, 文件夹按 Depth 复制自身或者连同全部子资源
+type DoCopy struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoCopy props 为 nil 时不复制 dead property
+func BuildDoCopy(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoCopy {
+	return &DoCopy{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoCopy) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	sourcePath := CleanPath(RelativePath(r))
+	destinationPath, err := ParseDestinationHeader(w, r)
+	if err != nil {
+		logrus.Debugf("COPY %s: %v", sourcePath, err)
+		return
+	}
+	destination := model.BuildPathInfo(destinationPath)
+	sourcePath = model.BuildPathInfo(sourcePath).Path
+	if len(destination.Name) <= 0 || sourcePath == destination.Path ||
+		strings.HasPrefix(destination.Path, strings.TrimSuffix(sourcePath, "/")+"/") {
+		// 不能复制到根文件夹、自身或者自身的子路径下
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	// COPY 只允许 Depth 为 0 或 infinity
+	depth := Depth(r)
+	if depth == 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !CheckLocks(transaction, r, w, d.resourceLocks, destination.ParentPath) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, destination.Path) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	so, err := d.store.StoredObject(transaction, sourcePath)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if !CheckPreconditions(transaction, d.store, d.resourceLocks, w, r, sourcePath, so) {
+		return
+	}
+	parent, err := d.store.StoredObject(transaction, destination.ParentPath)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !parent.IsFolder) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	status, ok := clearDestination(transaction, d.store, d.props, d.resourceLocks, w, r, destination.Path)
+	if !ok {
+		return
+	}
+
+	var failures []memberStatus
+	if !d.copyTree(transaction, sourcePath, destination.Path, so, depth, &failures) &&
+		len(failures) == 1 && failures[0].path == destination.Path {
+		w.WriteHeader(failures[0].status)
+		return
+	}
+	if len(failures) <= 0 {
+		w.WriteHeader(status)
+		return
+	}
+	// 部分子资源复制失败, 列出失败的目标路径
+	writeFailures(w, r, sourcePath, failures)
+}
+
+//copyTree 复制 sourcePath 到 destinationPath, Store 不能整体复制文件夹时逐个复制子资源, 返回 destinationPath 是否已经创建
+func (d *DoCopy) copyTree(transaction model.Transaction, sourcePath string, destinationPath string,
+	so *model.StoredObject, depth int, failures *[]memberStatus) bool {
+	if !so.IsFolder || depth != 0 {
+		err := d.store.Copy(transaction, sourcePath, destinationPath)
+		if err == nil {
+			d.copyProps(sourcePath, destinationPath, true)
+			return true
+		}
+		if !so.IsFolder || !errors.Is(err, store.ErrNotSupported) {
+			logrus.Errorf("COPY %s to %s failed: %v", sourcePath, destinationPath, err)
+			*failures = append(*failures, memberStatus{path: destinationPath, isFolder: so.IsFolder, status: StoreErrorStatus(err)})
+			return false
+		}
+	}
+
+	if err := d.store.CreateFolder(transaction, destinationPath); err != nil {
+		logrus.Errorf("COPY %s to %s failed: %v", sourcePath, destinationPath, err)
+		*failures = append(*failures, memberStatus{path: destinationPath, isFolder: true, status: StoreErrorStatus(err)})
+		return false
+	}
+	d.copyProps(sourcePath, destinationPath, false)
+	if depth == 0 {
+		return true
+	}
+	names, err := d.store.ChildrenNames(transaction, sourcePath)
+	if err != nil {
+		*failures = append(*failures, memberStatus{path: destinationPath, isFolder: true, status: StoreErrorStatus(err)})
+		return true
+	}
+	for _, name := range names {
+		child, err := d.store.StoredObject(transaction, path.Join(sourcePath, name))
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			*failures = append(*failures, memberStatus{path: path.Join(destinationPath, name), status: StoreErrorStatus(err)})
+			continue
+		}
+		d.copyTree(transaction, path.Join(sourcePath, name), path.Join(destinationPath, name), child, depth, failures)
+	}
+	return true
+}
+
+func (d *DoCopy) copyProps(sourcePath string, destinationPath string, recursive bool) {
+	if d.props == nil {
+		return
+	}
+	if err := d.props.Copy(sourcePath, destinationPath, recursive); err != nil {
+		logrus.Errorf("COPY %s properties: %v", sourcePath, err)
+	}
+}
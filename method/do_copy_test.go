@@ -0,0 +1,92 @@
+package method
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//memberCopyStore 不能整体复制文件夹, 复制 bad.txt 失败
+type memberCopyStore struct {
+	*store.MemoryStore
+}
+
+func (s memberCopyStore) Copy(transaction model.Transaction, path string, destinationPath string) error {
+	so, err := s.StoredObject(transaction, path)
+	if err != nil {
+		return err
+	}
+	if so.IsFolder {
+		return store.ErrNotSupported
+	}
+	if strings.HasSuffix(path, "/bad.txt") {
+		return errors.New("copy failed")
+	}
+	return s.MemoryStore.Copy(transaction, path, destinationPath)
+}
+
+func TestDoCopy(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/a/sub"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/a/1.txt", "/a/sub/2.txt", "/a/sub/bad.txt"} {
+		if _, err := memoryStore.SetResourceContent(transaction, file, strings.NewReader(file), "text/plain", -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copyRequest := func(webdavStore store.Store, path string, destination string, depth string, overwrite string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("COPY", path, nil)
+		r.Header.Set(DestinationKey, destination)
+		if len(depth) > 0 {
+			r.Header.Set("Depth", depth)
+		}
+		if len(overwrite) > 0 {
+			r.Header.Set("Overwrite", overwrite)
+		}
+		BuildDoCopy(webdavStore, nil, nil).Execute(transaction, w, r)
+		return w
+	}
+
+	if w := copyRequest(memoryStore, "/a/1.txt", "/a/one.txt", "", ""); w.Code != http.StatusCreated {
+		t.Errorf("copy file: %d", w.Code)
+	}
+	if w := copyRequest(memoryStore, "/a/1.txt", "/a/one.txt", "", "F"); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("copy without overwrite: %d", w.Code)
+	}
+	if w := copyRequest(memoryStore, "/a/1.txt", "/a/one.txt", "", "T"); w.Code != http.StatusNoContent {
+		t.Errorf("copy with overwrite: %d", w.Code)
+	}
+	if w := copyRequest(memoryStore, "/a", "/empty", "0", ""); w.Code != http.StatusCreated {
+		t.Errorf("copy depth 0: %d", w.Code)
+	}
+	if names, _ := memoryStore.ChildrenNames(transaction, "/empty"); len(names) != 0 {
+		t.Errorf("depth 0 copied children %v", names)
+	}
+	if w := copyRequest(memoryStore, "/a", "/a/sub/a", "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("copy into itself: %d", w.Code)
+	}
+	if w := copyRequest(memoryStore, "/a", "/b", "1", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("copy depth 1: %d", w.Code)
+	}
+
+	// 逐个复制, bad.txt 失败时返回 207
+	w := copyRequest(memberCopyStore{memoryStore}, "/a", "/c", "infinity", "")
+	if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "<D:href>/c/sub/bad.txt</D:href>") {
+		t.Errorf("partial copy: %d %s", w.Code, w.Body.String())
+	}
+	for _, p := range []string{"/c/1.txt", "/c/sub/2.txt"} {
+		if _, err := memoryStore.StoredObject(transaction, p); err != nil {
+			t.Errorf("%s not copied: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package method
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoDelete 删除资源, 文件夹连同其子资源一起删除, 是否进入回收站由 Store 决定
+type DoDelete struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoDelete props 为 nil 时不删除 dead property
+func BuildDoDelete(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoDelete {
+	return &DoDelete{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+//memberStatus 删除、移动或复制失败的子资源
+type memberStatus struct {
+	path     string
+	isFolder bool
+	status   int
+}
+
+func (d *DoDelete) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	info := model.BuildPathInfo(p)
+	if len(info.Name) <= 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !CheckLocks(transaction, r, w, d.resourceLocks, info.ParentPath) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, p) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+	so, err := d.store.StoredObject(transaction, p)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if !CheckPreconditions(transaction, d.store, d.resourceLocks, w, r, p, so) {
+		return
+	}
+
+	var failures []memberStatus
+	if !d.removeTree(transaction, r, w, p, so.IsFolder, &failures) && len(failures) == 1 && failures[0].path == p {
+		w.WriteHeader(failures[0].status)
+		return
+	}
+	if len(failures) <= 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// 部分子资源删除失败, 列出失败的子资源, 它们的上级文件夹也没有删除
+	writeFailures(w, r, p, failures)
+}
+
+//writeFailures 以 207 列出处理失败的子资源
+func writeFailures(w http.ResponseWriter, r *http.Request, p string, failures []memberStatus) {
+	ms := buildMultistatus(w)
+	for _, failure := range failures {
+		if err := ms.writeResponse(Href(failure.path, failure.isFolder), failure.status, nil); err != nil {
+			logrus.Errorf("%s %s failed: %v", r.Method, p, err)
+			break
+		}
+	}
+	if err := ms.close(); err != nil {
+		logrus.Errorf("%s %s failed: %v", r.Method, p, err)
+	}
+}
+
+//removeTree 删除 p, 子资源中有被锁定的资源时只删除未锁定的部分, 返回 p 是否已经删除
+func (d *DoDelete) removeTree(transaction model.Transaction, r *http.Request, w http.ResponseWriter,
+	p string, isFolder bool, failures *[]memberStatus) bool {
+	if !CheckLocks(transaction, r, w, d.resourceLocks, p) {
+		*failures = append(*failures, memberStatus{path: p, isFolder: isFolder, status: http.StatusLocked})
+		return false
+	}
+	if d.resourceLocks != nil && isFolder {
+		// 临时锁定整个文件夹, 失败说明有子资源被锁定
+		owner := "doDelete" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		if _, ok := d.resourceLocks.Lock(transaction, p, owner, true, locking.DepthInfinity, TempTimeout, true); ok {
+			defer d.resourceLocks.UnlockTemporaryLockedObjects(transaction, p, owner)
+		} else if !d.removeChildren(transaction, r, w, p, failures) {
+			return false
+		}
+	}
+
+	if err := d.store.RemoveObject(transaction, p); err != nil && !errors.Is(err, store.ErrNotFound) {
+		logrus.Errorf("DELETE %s failed: %v", p, err)
+		*failures = append(*failures, memberStatus{path: p, isFolder: isFolder, status: StoreErrorStatus(err)})
+		return false
+	}
+	// 资源删除后其上的锁也失效
+	if d.resourceLocks != nil {
+		d.resourceLocks.RemoveLocks(transaction, p)
+	}
+	if d.props != nil {
+		if err := d.props.Remove(p); err != nil {
+			logrus.Errorf("DELETE %s properties: %v", p, err)
+		}
+	}
+	return true
+}
+
+//removeChildren 逐个删除文件夹 p 的子资源, 全部删除时返回 true
+func (d *DoDelete) removeChildren(transaction model.Transaction, r *http.Request, w http.ResponseWriter,
+	p string, failures *[]memberStatus) bool {
+	names, err := d.store.ChildrenNames(transaction, p)
+	if err != nil {
+		*failures = append(*failures, memberStatus{path: p, isFolder: true, status: StoreErrorStatus(err)})
+		return false
+	}
+	removed := true
+	for _, name := range names {
+		childPath := path.Join(p, name)
+		child, err := d.store.StoredObject(transaction, childPath)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			*failures = append(*failures, memberStatus{path: childPath, status: StoreErrorStatus(err)})
+			removed = false
+			continue
+		}
+		if !d.removeTree(transaction, r, w, childPath, child.IsFolder, failures) {
+			removed = false
+		}
+	}
+	return removed
+}
@@ -0,0 +1,134 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//fakeLocks 将 locked 中的路径视为被其他客户端独占锁定
+type fakeLocks struct {
+	locked map[string]bool
+}
+
+func (l fakeLocks) Lock(transaction model.Transaction, path string, owner string,
+	exclusive bool, depth int32, timeout int32, temporary bool) (locking.LockedObject, bool) {
+	for locked := range l.locked {
+		if locked == path || strings.HasPrefix(locked, path+"/") {
+			return locking.LockedObject{}, false
+		}
+	}
+	return locking.LockedObject{Path: path, Id: "lock-" + path, Owner: owner, Exclusive: exclusive}, true
+}
+
+func (l fakeLocks) Unlock(transaction model.Transaction, id string, owner string) bool {
+	return true
+}
+
+func (l fakeLocks) UnlockTemporaryLockedObjects(transaction model.Transaction, path string, owner string) {
+}
+
+func (l fakeLocks) CheckTimeouts(transaction model.Transaction, temporary bool) {
+}
+
+func (l fakeLocks) ExclusiveLock(transaction model.Transaction, path string, owner string, depth, timeout int32) bool {
+	_, ok := l.Lock(transaction, path, owner, true, depth, timeout, false)
+	return ok
+}
+
+func (l fakeLocks) SharedLock(transaction model.Transaction, path string, owner string, depth int32, timeout int32) bool {
+	_, ok := l.Lock(transaction, path, owner, false, depth, timeout, false)
+	return ok
+}
+
+func (l fakeLocks) LockedObjectByID(transaction model.Transaction, id string) locking.LockedObject {
+	return locking.LockedObject{}
+}
+
+func (l fakeLocks) LockedObjectsByPath(transaction model.Transaction, path string) []locking.LockedObject {
+	if l.locked[path] {
+		return []locking.LockedObject{{Path: path, Id: "lock-" + path, Owner: "other", Exclusive: true}}
+	}
+	return nil
+}
+
+func (l fakeLocks) TempLockedObjectByID(transaction model.Transaction, id string) locking.LockedObject {
+	return locking.LockedObject{}
+}
+
+func (l fakeLocks) Move(transaction model.Transaction, path string, destinationPath string) {
+	var moved []string
+	for locked := range l.locked {
+		if locked == path || strings.HasPrefix(locked, path+"/") {
+			delete(l.locked, locked)
+			moved = append(moved, destinationPath+locked[len(path):])
+		}
+	}
+	for _, locked := range moved {
+		l.locked[locked] = true
+	}
+}
+
+func (l fakeLocks) RemoveLocks(transaction model.Transaction, path string) {
+	for locked := range l.locked {
+		if locked == path || strings.HasPrefix(locked, path+"/") {
+			delete(l.locked, locked)
+		}
+	}
+}
+
+func (l fakeLocks) Refresh(transaction model.Transaction, id string, timeout int32) locking.LockedObject {
+	return locking.LockedObject{}
+}
+
+func (l fakeLocks) TempLockedObjectsByPath(transaction model.Transaction, path string) []locking.LockedObject {
+	return nil
+}
+
+func TestDoDelete(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/a/b", "/a/c"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/a/b/1.txt", "/a/c/2.txt", "/a/3.txt"} {
+		if _, err := memoryStore.SetResourceContent(transaction, file, strings.NewReader("x"), "text/plain", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doDelete := BuildDoDelete(memoryStore, nil, fakeLocks{locked: map[string]bool{"/a/b/1.txt": true}})
+
+	w := httptest.NewRecorder()
+	doDelete.Execute(transaction, w, httptest.NewRequest(http.MethodDelete, "/a", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "<D:href>/a/b/1.txt</D:href><D:status>HTTP/1.1 423 Locked</D:status>") ||
+		strings.Count(body, "<D:response>") != 1 {
+		t.Errorf("partial delete: %d %s", w.Code, body)
+	}
+	for p, exists := range map[string]bool{"/a": true, "/a/b": true, "/a/b/1.txt": true, "/a/c": false, "/a/3.txt": false} {
+		if _, err := memoryStore.StoredObject(transaction, p); (err == nil) != exists {
+			t.Errorf("%s exists %v, want %v", p, err == nil, exists)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	doDelete.Execute(transaction, w, httptest.NewRequest(http.MethodDelete, "/a/b/1.txt", nil))
+	if w.Code != http.StatusLocked {
+		t.Errorf("locked file: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	BuildDoDelete(memoryStore, nil, nil).Execute(transaction, w, httptest.NewRequest(http.MethodDelete, "/a", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", w.Code)
+	}
+	if _, err := memoryStore.StoredObject(transaction, "/a/b/1.txt"); err == nil {
+		t.Errorf("/a/b/1.txt not deleted")
+	}
+}
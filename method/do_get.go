@@ -0,0 +1,283 @@
+package method
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoGet 返回文件内容, 支持 Range、If-Range 和 multipart/byteranges
+type DoGet struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoGet props 用于读取服务器记录的文件时间, 可以为 nil, resourceLocks 用于判断 If 头中的 lock token
+func BuildDoGet(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoGet {
+	return &DoGet{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoGet) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	so, err := d.store.StoredObject(transaction, p)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	OverlayTimes(d.props, p, so)
+	FolderHash(transaction, d.store, p, so)
+	if !CheckPreconditions(transaction, d.store, d.resourceLocks, w, r, p, so) {
+		return
+	}
+	w.Header().Set("ETag", ETag(so))
+	if so.IsFolder {
+		d.folderBody(transaction, w, r, p)
+		return
+	}
+	if r.Method == http.MethodGet && d.redirect(transaction, w, r, p) {
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(p, so))
+	content := &contentSeeker{
+		transaction: transaction,
+		store:       d.store,
+		path:        p,
+		size:        so.ContentLength,
+		lengths:     RangeLengths(r.Header.Get("Range"), so.ContentLength),
+	}
+	defer content.Close()
+	// ServeContent 处理 Range、If-Range 以及 multipart/byteranges
+	http.ServeContent(w, r, "", so.LastModified, content)
+	if content.err != nil {
+		logrus.Errorf("GET %s failed: %v", p, content.err)
+	}
+}
+
+//redirect 客户端允许重定向时以 302 返回文件的下载地址, 返回 false 时由服务器转发内容
+func (d *DoGet) redirect(transaction model.Transaction, w http.ResponseWriter, r *http.Request, p string) bool {
+	redirector, ok := d.store.(store.Redirector)
+	if !ok {
+		return false
+	}
+	userAgent := r.UserAgent()
+	if !RedirectUserAgent(userAgent) {
+		logrus.Debugf("GET %s from %q: proxy", p, userAgent)
+		return false
+	}
+	url, err := redirector.DownloadURL(transaction, p)
+	if err != nil {
+		logrus.Warnf("GET %s from %q: proxy, download url unavailable: %v", p, userAgent, err)
+		return false
+	}
+	logrus.Infof("GET %s from %q: redirect", p, userAgent)
+	http.Redirect(w, r, url, http.StatusFound)
+	return true
+}
+
+//RedirectUserAgent 判断 userAgent 是否使用重定向下载, ProxyUserAgents 优先于 RedirectUserAgents
+func RedirectUserAgent(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, pattern := range config.WebConf.ProxyUserAgents {
+		if strings.Contains(userAgent, strings.ToLower(pattern)) {
+			return false
+		}
+	}
+	for _, pattern := range config.WebConf.RedirectUserAgents {
+		if pattern == "*" || strings.Contains(userAgent, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+//folderBody 以 html 列出文件夹下的资源
+func (d *DoGet) folderBody(transaction model.Transaction, w http.ResponseWriter, r *http.Request, p string) {
+	names, err := d.store.ChildrenNames(transaction, p)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "<html><head><title>%s</title></head><body><ul>\n", html.EscapeString(p))
+	for _, name := range names {
+		href := path.Join(config.WebConf.ContextPath, p, RewriteUrl(name))
+		_, _ = fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(name))
+	}
+	_, _ = fmt.Fprint(w, "</ul></body></html>\n")
+}
+
+//ContentType 返回文件的 Content-Type, 存储中没有时按扩展名推断
+func ContentType(p string, so *model.StoredObject) string {
+	if len(so.MineType) > 0 {
+		return so.MineType
+	}
+	if contentType := mime.TypeByExtension(path.Ext(p)); len(contentType) > 0 {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+//contentSeeker 将 Store 中的文件包装为 io.ReadSeeker, Seek 之后第一次 Read 时才从对应位置打开内容
+//从请求的范围开始读取时只打开该范围, 读完后继续读取再从当前位置重新打开
+type contentSeeker struct {
+	transaction model.Transaction
+	store       store.Store
+	path        string
+	size        int64
+	// 请求的范围, 起始位置对应长度
+	lengths map[int64]int64
+
+	offset int64
+	// 打开的内容的结束位置
+	end    int64
+	reader io.ReadCloser
+	err    error
+}
+
+func (c *contentSeeker) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			if c.offset >= c.size {
+				return 0, io.EOF
+			}
+			if err := c.open(); err != nil {
+				c.err = err
+				return 0, err
+			}
+		}
+		n, err := c.reader.Read(p)
+		c.offset += int64(n)
+		if err == io.EOF && c.offset == c.end && c.end < c.size {
+			// 只打开了请求的范围, 例如 If-Range 不匹配时返回整个文件
+			c.Close()
+			if n <= 0 {
+				continue
+			}
+			err = nil
+		}
+		if err != nil && err != io.EOF {
+			c.err = err
+		}
+		return n, err
+	}
+}
+
+func (c *contentSeeker) open() error {
+	if rangeReader, ok := c.store.(store.RangeReader); ok {
+		length := c.size - c.offset
+		if requested, ok := c.lengths[c.offset]; ok && requested < length {
+			length = requested
+		}
+		reader, err := rangeReader.ResourceRange(c.transaction, c.path, c.offset, length)
+		c.reader = reader
+		c.end = c.offset + length
+		return err
+	}
+	c.end = c.size
+	reader, err := c.store.ResourceContent(c.transaction, c.path)
+	if err != nil {
+		return err
+	}
+	c.reader = reader
+	if _, err := io.CopyN(ioutil.Discard, reader, c.offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *contentSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of content")
+	}
+	if offset != c.offset {
+		c.Close()
+		c.offset = offset
+	}
+	return offset, nil
+}
+
+func (c *contentSeeker) Close() error {
+	if c.reader == nil {
+		return nil
+	}
+	err := c.reader.Close()
+	c.reader = nil
+	return err
+}
+
+//RangeLengths 解析 Range 头, 返回每个范围的起始位置和长度, 格式不正确的范围被忽略
+func RangeLengths(header string, size int64) map[int64]int64 {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil
+	}
+	lengths := map[int64]int64{}
+	for _, spec := range strings.Split(header[len("bytes="):], ",") {
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			continue
+		}
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		var start, end int64
+		if len(first) <= 0 {
+			// -n 为最后 n 字节
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			start, end = size-n, size
+		} else {
+			var err error
+			if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+				continue
+			}
+			end = size
+			if len(last) > 0 {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+					continue
+				}
+				end++
+				if end > size {
+					end = size
+				}
+			}
+		}
+		if start < 0 || end <= start {
+			continue
+		}
+		if length, ok := lengths[start]; !ok || end-start > length {
+			lengths[start] = end - start
+		}
+	}
+	return lengths
+}
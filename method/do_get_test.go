@@ -0,0 +1,139 @@
+package method
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestDoGetRange(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("0123456789"), "text/plain", 10); err != nil {
+		t.Fatal(err)
+	}
+	doGet := BuildDoGet(memoryStore, nil, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	doGet.Execute(transaction, w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("single range: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r.Header.Set("Range", "bytes=0-1,-2")
+	doGet.Execute(transaction, w, r)
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges") ||
+		!strings.Contains(string(body), "01") || !strings.Contains(string(body), "89") {
+		t.Errorf("multi range: %d %v %q", w.Code, w.Header(), body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r.Header.Set("Range", "bytes=20-")
+	doGet.Execute(transaction, w, r)
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	doGet.Execute(transaction, w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing: %d", w.Code)
+	}
+}
+
+//rangeStore 记录打开的范围
+type rangeStore struct {
+	*store.MemoryStore
+	ranges [][2]int64
+}
+
+func (s *rangeStore) ResourceRange(transaction model.Transaction, p string, offset int64, length int64) (io.ReadCloser, error) {
+	s.ranges = append(s.ranges, [2]int64{offset, length})
+	return s.MemoryStore.ResourceRange(transaction, p, offset, length)
+}
+
+func TestDoGetRangeLength(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("0123456789"), "text/plain", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rangeHeader string
+		ifRange     string
+		body        string
+		ranges      [][2]int64
+	}{
+		{"bytes=2-5", "", "2345", [][2]int64{{2, 4}}},
+		{"bytes=0-1,-2", "", "", [][2]int64{{0, 2}, {8, 2}}},
+		{"bytes=7-", "", "789", [][2]int64{{7, 3}}},
+		// If-Range 不匹配时返回整个文件, 读完请求的范围后重新打开
+		{"bytes=0-1", `"other"`, "0123456789", [][2]int64{{0, 2}, {2, 8}}},
+	}
+	for _, test := range tests {
+		rangeStore := &rangeStore{MemoryStore: memoryStore}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		r.Header.Set("Range", test.rangeHeader)
+		if len(test.ifRange) > 0 {
+			r.Header.Set("If-Range", test.ifRange)
+		}
+		BuildDoGet(rangeStore, nil, nil).Execute(transaction, w, r)
+		if (len(test.body) > 0 && w.Body.String() != test.body) || !reflect.DeepEqual(rangeStore.ranges, test.ranges) {
+			t.Errorf("range %s: %d %q, opened %v", test.rangeHeader, w.Code, w.Body.String(), rangeStore.ranges)
+		}
+	}
+}
+
+type redirectStore struct {
+	*store.MemoryStore
+}
+
+func (s redirectStore) DownloadURL(transaction model.Transaction, p string) (string, error) {
+	return "https://download.example.com" + p, nil
+}
+
+func TestDoGetRedirect(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("0123456789"), "text/plain", 10); err != nil {
+		t.Fatal(err)
+	}
+	defer func(redirect []string, proxy []string) {
+		config.WebConf.RedirectUserAgents = redirect
+		config.WebConf.ProxyUserAgents = proxy
+	}(config.WebConf.RedirectUserAgents, config.WebConf.ProxyUserAgents)
+	config.WebConf.RedirectUserAgents = []string{"*"}
+	config.WebConf.ProxyUserAgents = []string{"Microsoft-WebDAV"}
+	doGet := BuildDoGet(redirectStore{memoryStore}, nil, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r.Header.Set("User-Agent", "rclone/v1.60")
+	doGet.Execute(transaction, w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://download.example.com/a.txt" {
+		t.Errorf("redirect: %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r.Header.Set("User-Agent", "Microsoft-WebDAV-MiniRedir/10.0")
+	doGet.Execute(transaction, w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("proxy: %d %q", w.Code, w.Body.String())
+	}
+}
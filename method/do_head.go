@@ -0,0 +1,15 @@
+package method
+
+import (
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/store"
+)
+
+//DoHead 与 GET 返回相同的头但不返回内容, 不重定向到下载地址
+type DoHead struct {
+	*DoGet
+}
+
+func BuildDoHead(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoHead {
+	return &DoHead{DoGet: BuildDoGet(webdavStore, props, resourceLocks)}
+}
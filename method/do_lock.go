@@ -0,0 +1,179 @@
+package method
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoLock 锁定资源或者刷新已有的锁, 锁定不存在的资源时创建空文件
+type DoLock struct {
+	store         store.Store
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoLock resourceLocks 为 nil 时表示未启用锁, LOCK 返回 405
+func BuildDoLock(webdavStore store.Store, resourceLocks locking.IResourceLocks) *DoLock {
+	return &DoLock{
+		store:         webdavStore,
+		resourceLocks: resourceLocks,
+	}
+}
+
+//lockInfo LOCK 请求体
+type lockInfo struct {
+	XMLName   xml.Name `xml:"DAV: lockinfo"`
+	LockScope struct {
+		Exclusive *struct{} `xml:"DAV: exclusive"`
+		Shared    *struct{} `xml:"DAV: shared"`
+	} `xml:"DAV: lockscope"`
+	LockType struct {
+		Write *struct{} `xml:"DAV: write"`
+	} `xml:"DAV: locktype"`
+	Owner innerXML `xml:"DAV: owner"`
+}
+
+func (d *DoLock) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	if d.resourceLocks == nil {
+		// 未启用锁
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	timeout := LockTimeout(r)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logrus.Errorf("LOCK %s failed: %v", p, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(body)) <= 0 {
+		d.refresh(transaction, w, r, p, timeout)
+		return
+	}
+
+	var info lockInfo
+	if err := xml.Unmarshal(body, &info); err != nil ||
+		(info.LockScope.Exclusive == nil) == (info.LockScope.Shared == nil) || info.LockType.Write == nil {
+		logrus.Debugf("LOCK %s bad request: %v", p, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// LOCK 只允许 Depth 为 0 或 infinity, 默认为 infinity
+	depth := locking.DepthInfinity
+	switch Depth(r) {
+	case 0:
+		depth = 0
+	case 1:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, err = d.store.StoredObject(transaction, p)
+	created := errors.Is(err, store.ErrNotFound)
+	if err != nil && !created {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if created {
+		parentPath := model.BuildPathInfo(p).ParentPath
+		if !CheckLocks(transaction, r, w, d.resourceLocks, parentPath) {
+			w.WriteHeader(http.StatusLocked)
+			return
+		}
+		parent, err := d.store.StoredObject(transaction, parentPath)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && !parent.IsFolder) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(StoreErrorStatus(err))
+			return
+		}
+	}
+
+	exclusive := info.LockScope.Exclusive != nil
+	owner := strings.TrimSpace(string(info.Owner))
+	lo, ok := d.resourceLocks.Lock(transaction, p, owner, exclusive, depth, timeout, false)
+	if !ok {
+		WriteConditionError(w, http.StatusLocked, "no-conflicting-lock")
+		return
+	}
+	if created {
+		// 锁定不存在的资源时创建空文件
+		if _, err := d.store.SetResourceContent(transaction, p, bytes.NewReader(nil), "", 0); err != nil {
+			logrus.Errorf("LOCK %s failed: %v", p, err)
+			d.resourceLocks.Unlock(transaction, lo.Id, "")
+			w.WriteHeader(StoreErrorStatus(err))
+			return
+		}
+	}
+
+	w.Header().Set("Lock-Token", "<"+LockTokenPrefix+lo.Id+">")
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeLockDiscovery(w, status, lo)
+}
+
+//refresh 没有请求体的 LOCK 刷新 If 头中作用于 p 的锁
+func (d *DoLock) refresh(transaction model.Transaction, w http.ResponseWriter, r *http.Request, p string, timeout int32) {
+	ids := LockIdFromIfHeader(r)
+	if len(ids) <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, lo := range CoveringLocks(transaction, d.resourceLocks, p) {
+		for _, id := range ids {
+			if lo.Id != id {
+				continue
+			}
+			refreshed := d.resourceLocks.Refresh(transaction, id, timeout)
+			if len(refreshed.Id) <= 0 {
+				break
+			}
+			writeLockDiscovery(w, http.StatusOK, refreshed)
+			return
+		}
+	}
+	WriteConditionError(w, http.StatusPreconditionFailed, "lock-token-matches-request-uri")
+}
+
+func writeLockDiscovery(w http.ResponseWriter, status int, lo locking.LockedObject) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s<D:prop xmlns:D=\"DAV:\"><D:lockdiscovery>%s</D:lockdiscovery></D:prop>\n",
+		xml.Header, ActiveLock(lo))
+}
+
+//LockTimeout 读取 Timeout 头, 使用第一个可以识别的值, 没有时为 DefaultTimeout, Infinite 和过长的时间限制为 MaxTimeout
+func LockTimeout(r *http.Request) int32 {
+	for _, value := range strings.Split(r.Header.Get("Timeout"), ",") {
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(value, "Infinite") {
+			return MaxTimeout
+		}
+		if len(value) <= len("Second-") || !strings.EqualFold(value[:len("Second-")], "Second-") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(value[len("Second-"):], 10, 64)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		if seconds > MaxTimeout {
+			return MaxTimeout
+		}
+		return int32(seconds)
+	}
+	return DefaultTimeout
+}
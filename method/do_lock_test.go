@@ -0,0 +1,209 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+const exclusiveLockInfo = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype>
+<D:owner><D:href>mailto:alice@example.com</D:href></D:owner></D:lockinfo>`
+
+func TestDoLock(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if err := memoryStore.CreateFolder(transaction, "/docs"); err != nil {
+		t.Fatal(err)
+	}
+	resourceLocks := locking.Build()
+	doLock := BuildDoLock(memoryStore, resourceLocks)
+	lockRequest := func(p string, body string, header string, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("LOCK", p, strings.NewReader(body))
+		if len(header) > 0 {
+			r.Header.Set(header, value)
+		}
+		doLock.Execute(transaction, w, r)
+		return w
+	}
+
+	// 锁定不存在的文件时创建空文件
+	w := lockRequest("/docs/a.docx", exclusiveLockInfo, "Timeout", "Second-600")
+	token := w.Header().Get("Lock-Token")
+	body := w.Body.String()
+	if w.Code != http.StatusCreated || !strings.HasPrefix(token, "<"+LockTokenPrefix) ||
+		!strings.Contains(body, `<D:owner><href xmlns="DAV:">mailto:alice@example.com</href></D:owner>`) ||
+		!strings.Contains(body, "<D:exclusive/>") || !strings.Contains(body, "<D:depth>infinity</D:depth>") ||
+		!strings.Contains(body, "<D:timeout>Second-600</D:timeout>") || !strings.Contains(body, token[1:len(token)-1]) {
+		t.Fatalf("LOCK: %d %s %s", w.Code, token, body)
+	}
+	if so, err := memoryStore.StoredObject(transaction, "/docs/a.docx"); err != nil || so.ContentLength != 0 {
+		t.Errorf("empty file %v, err %v", so, err)
+	}
+	if w := lockRequest("/docs/a.docx", exclusiveLockInfo, "", ""); w.Code != http.StatusLocked {
+		t.Errorf("conflicting LOCK: %d", w.Code)
+	}
+	if w := lockRequest("/missing/a.docx", exclusiveLockInfo, "", ""); w.Code != http.StatusConflict {
+		t.Errorf("LOCK without parent: %d", w.Code)
+	}
+	if w := lockRequest("/docs/b.docx", "<D:lockinfo xmlns:D=\"DAV:\"/>", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("LOCK without scope: %d", w.Code)
+	}
+	// owner 保持原来的命名空间, 与请求中使用的前缀无关
+	w = lockRequest("/docs/c.docx", `<lockinfo xmlns="DAV:" xmlns:x="urn:example"><lockscope><shared/></lockscope><locktype><write/></locktype>`+
+		`<owner><href>mailto:bob@example.com</href><x:team x:id="1">docs</x:team></owner></lockinfo>`, "", "")
+	if body := w.Body.String(); w.Code != http.StatusCreated || !strings.Contains(body, `<D:owner><href xmlns="DAV:">mailto:bob@example.com</href>`+
+		`<team xmlns="urn:example" xmlns:a0="urn:example" a0:id="1">docs</team></D:owner>`) {
+		t.Errorf("default namespace owner: %d %s", w.Code, body)
+	}
+
+	// 刷新
+	if w := lockRequest("/docs/a.docx", "", "If", "("+token+")"); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), "<D:timeout>Second-3600</D:timeout>") {
+		t.Errorf("refresh: %d %s", w.Code, w.Body.String())
+	}
+	if w := lockRequest("/docs/a.docx", "", "If", "(<"+LockTokenPrefix+"other>)"); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("refresh with unknown token: %d", w.Code)
+	}
+
+	// If 头中的 lock token 对 GET 和 HEAD 同样成立
+	for method, handler := range map[string]IMethodExecutor{
+		http.MethodGet:  BuildDoGet(memoryStore, nil, resourceLocks),
+		http.MethodHead: BuildDoHead(memoryStore, nil, resourceLocks),
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/docs/a.docx", nil)
+		r.Header.Set("If", "("+token+")")
+		handler.Execute(transaction, w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s with token: %d", method, w.Code)
+		}
+	}
+
+	// 持有锁才能修改
+	put := func(header string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/docs/a.docx", strings.NewReader("content"))
+		if len(header) > 0 {
+			r.Header.Set("If", header)
+		}
+		BuildDoPut(memoryStore, nil, resourceLocks).Execute(transaction, w, r)
+		return w.Code
+	}
+	if status := put(""); status != http.StatusLocked {
+		t.Errorf("PUT without token: %d", status)
+	}
+	if status := put("(" + token + ")"); status != http.StatusNoContent {
+		t.Errorf("PUT with token: %d", status)
+	}
+
+	unlock := func(p string, lockToken string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("UNLOCK", p, nil)
+		r.Header.Set("Lock-Token", lockToken)
+		BuildDoUnlock(resourceLocks).Execute(transaction, w, r)
+		return w.Code
+	}
+	if status := unlock("/docs/a.docx", "<"+LockTokenPrefix+"other>"); status != http.StatusConflict {
+		t.Errorf("UNLOCK unknown token: %d", status)
+	}
+	if status := unlock("/docs", token); status != http.StatusConflict {
+		t.Errorf("UNLOCK on another resource: %d", status)
+	}
+	if status := unlock("/docs/a.docx", token); status != http.StatusNoContent {
+		t.Errorf("UNLOCK: %d", status)
+	}
+	if status := put(""); status != http.StatusNoContent {
+		t.Errorf("PUT after UNLOCK: %d", status)
+	}
+
+	// 删除资源时同时删除资源上的锁
+	token = lockRequest("/docs/a.docx", exclusiveLockInfo, "", "").Header().Get("Lock-Token")
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/docs/a.docx", nil)
+	r.Header.Set("If", "("+token+")")
+	BuildDoDelete(memoryStore, nil, resourceLocks).Execute(transaction, w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: %d", w.Code)
+	}
+	if status := put(""); status != http.StatusCreated {
+		t.Errorf("PUT after DELETE: %d", status)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int32
+	}{
+		{"", DefaultTimeout},
+		{"Second-60", 60},
+		{"Infinite, Second-60", MaxTimeout},
+		{"bogus, Second-120", 120},
+		{"Second-99999999999", MaxTimeout},
+		{"Second--5", DefaultTimeout},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("LOCK", "/", nil)
+		r.Header.Set("Timeout", test.header)
+		if got := LockTimeout(r); got != test.want {
+			t.Errorf("Timeout: %s: %d, want %d", test.header, got, test.want)
+		}
+	}
+}
+
+func TestDoLockShared(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	resourceLocks := locking.Build()
+	lock := func(owner string) string {
+		w := httptest.NewRecorder()
+		body := `<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:shared/></D:lockscope><D:locktype><D:write/></D:locktype>` +
+			`<D:owner>` + owner + `</D:owner></D:lockinfo>`
+		BuildDoLock(memoryStore, resourceLocks).Execute(transaction, w, httptest.NewRequest("LOCK", "/s.txt", strings.NewReader(body)))
+		if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			t.Fatalf("LOCK by %s: %d", owner, w.Code)
+		}
+		return w.Header().Get("Lock-Token")
+	}
+	alice := lock("alice")
+	bob := lock("bob")
+	if alice == bob {
+		t.Fatalf("shared locks use the same token %s", alice)
+	}
+
+	// bob 解锁不影响 alice 的锁
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("UNLOCK", "/s.txt", nil)
+	r.Header.Set("Lock-Token", bob)
+	BuildDoUnlock(resourceLocks).Execute(transaction, w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("UNLOCK: %d", w.Code)
+	}
+	discovery := LockDiscovery(transaction, resourceLocks, "/s.txt")
+	if !strings.Contains(discovery, alice[1:len(alice)-1]) || strings.Contains(discovery, bob[1:len(bob)-1]) ||
+		strings.Contains(discovery, "<D:owner>bob</D:owner>") {
+		t.Errorf("lockdiscovery after UNLOCK %s", discovery)
+	}
+}
+
+func TestDoLockDisabled(t *testing.T) {
+	var transaction model.Transaction
+	w := httptest.NewRecorder()
+	BuildDoLock(store.BuildMemoryStore(), nil).Execute(transaction, w, httptest.NewRequest("LOCK", "/a.txt", strings.NewReader(exclusiveLockInfo)))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("LOCK: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("UNLOCK", "/a.txt", nil)
+	r.Header.Set("Lock-Token", "<"+LockTokenPrefix+"a>")
+	BuildDoUnlock(nil).Execute(transaction, w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("UNLOCK: %d", w.Code)
+	}
+}
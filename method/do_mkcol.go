@@ -0,0 +1,63 @@
+package method
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoMkcol 创建文件夹, 父文件夹必须已经存在
+type DoMkcol struct {
+	store         store.Store
+	resourceLocks locking.IResourceLocks
+}
+
+func BuildDoMkcol(webdavStore store.Store, resourceLocks locking.IResourceLocks) *DoMkcol {
+	return &DoMkcol{
+		store:         webdavStore,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoMkcol) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	path := CleanPath(RelativePath(r))
+	info := model.BuildPathInfo(path)
+	if len(info.Name) <= 0 {
+		// 根文件夹总是存在
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !CheckLocks(transaction, r, w, d.resourceLocks, info.ParentPath) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+	// 不支持带请求体的 MKCOL
+	if r.ContentLength > 0 || len(r.TransferEncoding) > 0 {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	_, err := d.store.StoredObject(transaction, path)
+	if err == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+
+	if err := d.store.CreateFolder(transaction, path); err != nil {
+		if errors.Is(err, store.ErrExists) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		logrus.Errorf("MKCOL %s failed: %v", path, err)
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
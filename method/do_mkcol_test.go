@@ -0,0 +1,38 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestDoMkcol(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	doMkcol := BuildDoMkcol(memoryStore, nil)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/docs", "", http.StatusCreated},
+		{"/docs", "", http.StatusMethodNotAllowed},
+		{"/missing/docs", "", http.StatusConflict},
+		{"/other", "<mkcol/>", http.StatusUnsupportedMediaType},
+		{"/", "", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		doMkcol.Execute(transaction, w, httptest.NewRequest("MKCOL", test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("MKCOL %s body %q: %d, want %d", test.path, test.body, w.Code, test.status)
+		}
+	}
+	if so, err := memoryStore.StoredObject(transaction, "/docs"); err != nil || !so.IsFolder {
+		t.Errorf("folder not created: %v %v", so, err)
+	}
+}
@@ -0,0 +1,250 @@
+package method
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"path"
+	"strings"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoMove 移动资源, 同一文件夹下为重命名, 锁和 dead property 随资源一起移动
+type DoMove struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoMove props 为 nil 时不处理 dead property
+func BuildDoMove(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoMove {
+	return &DoMove{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoMove) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	path := CleanPath(RelativePath(r))
+	destinationPath, err := ParseDestinationHeader(w, r)
+	if err != nil {
+		logrus.Debugf("MOVE %s: %v", path, err)
+		return
+	}
+	source := model.BuildPathInfo(path)
+	destination := model.BuildPathInfo(destinationPath)
+	if len(source.Name) <= 0 || len(destination.Name) <= 0 || source.Path == destination.Path ||
+		strings.HasPrefix(destination.Path, source.Path+"/") {
+		// 不能移动根文件夹, 也不能移动到自身或者自身的子路径下
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !CheckLocks(transaction, r, w, d.resourceLocks, source.ParentPath) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, source.Path) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, destination.ParentPath) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, destination.Path) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	so, err := d.store.StoredObject(transaction, source.Path)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if !CheckPreconditions(transaction, d.store, d.resourceLocks, w, r, source.Path, so) {
+		return
+	}
+	parent, err := d.store.StoredObject(transaction, destination.ParentPath)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !parent.IsFolder) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	status, ok := clearDestination(transaction, d.store, d.props, d.resourceLocks, w, r, destination.Path)
+	if !ok {
+		return
+	}
+
+	err = d.move(transaction, source, destination)
+	if so.IsFolder && errors.Is(err, store.ErrNotSupported) {
+		d.moveFolder(transaction, w, r, source, destination, status)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("MOVE %s to %s failed: %v", source.Path, destination.Path, err)
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	d.moveMeta(transaction, source.Path, destination.Path)
+	w.WriteHeader(status)
+}
+
+//moveFolder Store 不能整体移动文件夹时逐个移动子资源, 部分子资源移动失败时返回 207 并列出失败的子资源
+func (d *DoMove) moveFolder(transaction model.Transaction, w http.ResponseWriter, r *http.Request,
+	source model.PathInfo, destination model.PathInfo, status int) {
+	var failures []memberStatus
+	if d.moveMembers(transaction, source, destination, &failures) {
+		d.moveMeta(transaction, source.Path, destination.Path)
+		w.WriteHeader(status)
+		return
+	}
+	if len(failures) == 1 && failures[0].path == source.Path {
+		w.WriteHeader(failures[0].status)
+		return
+	}
+	writeFailures(w, r, source.Path, failures)
+}
+
+//moveMembers 在目标位置创建文件夹并移动 source 的全部子资源, 全部移动后删除 source, 返回 source 是否已经全部移动
+//Store 实现 store.BatchMover 时一次请求移动多个子资源, 子文件夹也不能整体移动时递归处理
+//没有全部移动时, 已经移动的子资源的锁和 dead property 在这里随之移动, 否则由调用方整体移动
+func (d *DoMove) moveMembers(transaction model.Transaction, source model.PathInfo, destination model.PathInfo,
+	failures *[]memberStatus) bool {
+	if err := d.store.CreateFolder(transaction, destination.Path); err != nil {
+		logrus.Errorf("MOVE %s to %s failed: %v", source.Path, destination.Path, err)
+		*failures = append(*failures, memberStatus{path: source.Path, isFolder: true, status: StoreErrorStatus(err)})
+		return false
+	}
+	names, err := d.store.ChildrenNames(transaction, source.Path)
+	if err != nil {
+		*failures = append(*failures, memberStatus{path: source.Path, isFolder: true, status: StoreErrorStatus(err)})
+		return false
+	}
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, path.Join(source.Path, name))
+	}
+	var failed map[string]error
+	if mover, ok := d.store.(store.BatchMover); ok {
+		failed = mover.MoveAll(transaction, paths, destination.Path)
+	} else {
+		failed = map[string]error{}
+		for _, p := range paths {
+			if err := d.store.Move(transaction, p, destination.Path); err != nil {
+				failed[p] = err
+			}
+		}
+	}
+
+	var moved [][2]string
+	complete := true
+	for _, p := range paths {
+		member := model.BuildPathInfo(p)
+		target := model.BuildPathInfo(path.Join(destination.Path, member.Name))
+		err, ok := failed[p]
+		if ok && errors.Is(err, store.ErrNotSupported) {
+			ok = !d.moveMembers(transaction, member, target, failures)
+			err = nil
+		}
+		if !ok {
+			moved = append(moved, [2]string{member.Path, target.Path})
+			continue
+		}
+		complete = false
+		if err != nil {
+			logrus.Errorf("MOVE %s to %s failed: %v", member.Path, target.Path, err)
+			so, _ := d.store.StoredObject(transaction, member.Path)
+			*failures = append(*failures, memberStatus{path: member.Path, isFolder: so != nil && so.IsFolder, status: StoreErrorStatus(err)})
+		}
+	}
+	if complete {
+		err := d.store.RemoveObject(transaction, source.Path)
+		if err == nil || errors.Is(err, store.ErrNotFound) {
+			return true
+		}
+		logrus.Errorf("MOVE %s failed: %v", source.Path, err)
+		*failures = append(*failures, memberStatus{path: source.Path, isFolder: true, status: StoreErrorStatus(err)})
+	}
+	for _, m := range moved {
+		d.moveMeta(transaction, m[0], m[1])
+	}
+	return false
+}
+
+//moveMeta 锁和 dead property 随资源一起移动
+func (d *DoMove) moveMeta(transaction model.Transaction, sourcePath string, destinationPath string) {
+	if d.props != nil {
+		if err := d.props.Move(sourcePath, destinationPath); err != nil {
+			logrus.Errorf("MOVE %s properties: %v", sourcePath, err)
+		}
+	}
+	if d.resourceLocks != nil {
+		d.resourceLocks.Move(transaction, sourcePath, destinationPath)
+	}
+}
+
+//clearDestination MOVE 和 COPY 的目标已存在时按 Overwrite 删除, 返回成功时的状态码
+//与 DELETE 一样检查目标及其子资源上的锁并删除目标上的锁, 不能继续时写入错误响应并返回 false
+func clearDestination(transaction model.Transaction, webdavStore store.Store, props store.PropertyStore,
+	resourceLocks locking.IResourceLocks, w http.ResponseWriter, r *http.Request, destinationPath string) (int, bool) {
+	so, err := webdavStore.StoredObject(transaction, destinationPath)
+	if errors.Is(err, store.ErrNotFound) {
+		return http.StatusCreated, true
+	}
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return 0, false
+	}
+	if !Overwrite(r) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return 0, false
+	}
+	remover := BuildDoDelete(webdavStore, props, resourceLocks)
+	var failures []memberStatus
+	if !remover.removeTree(transaction, r, w, destinationPath, so.IsFolder, &failures) {
+		if len(failures) == 1 && failures[0].path == destinationPath {
+			w.WriteHeader(failures[0].status)
+		} else {
+			// 目标中有被锁定或者删除失败的子资源, 已经删除的部分不能恢复
+			writeFailures(w, r, destinationPath, failures)
+		}
+		return 0, false
+	}
+	return http.StatusNoContent, true
+}
+
+//move 同一文件夹下重命名, 名称相同时移动, 两者都不同时先移动再重命名, 第二步失败时撤销第一步
+func (d *DoMove) move(transaction model.Transaction, source model.PathInfo, destination model.PathInfo) error {
+	if source.ParentPath == destination.ParentPath {
+		return d.store.Rename(transaction, source.Path, destination.Name)
+	}
+	if source.Name == destination.Name {
+		return d.store.Move(transaction, source.Path, destination.ParentPath)
+	}
+
+	moved := model.BuildPathInfo(destination.ParentPath + "/" + source.Name).Path
+	err := d.store.Move(transaction, source.Path, destination.ParentPath)
+	if errors.Is(err, store.ErrExists) {
+		// 目标文件夹中已有同名资源, 改为先在原文件夹中重命名
+		renamed := model.BuildPathInfo(source.ParentPath + "/" + destination.Name).Path
+		if err := d.store.Rename(transaction, source.Path, destination.Name); err != nil {
+			return err
+		}
+		if err := d.store.Move(transaction, renamed, destination.ParentPath); err != nil {
+			d.rollback(d.store.Rename(transaction, renamed, source.Name), renamed)
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.store.Rename(transaction, moved, destination.Name); err != nil {
+		d.rollback(d.store.Move(transaction, moved, source.ParentPath), moved)
+		return err
+	}
+	return nil
+}
+
+func (d *DoMove) rollback(err error, path string) {
+	if err != nil {
+		logrus.Errorf("MOVE rollback of %s failed: %v", path, err)
+	}
+}
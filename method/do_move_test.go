@@ -0,0 +1,269 @@
+package method
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//failingRenameStore 移动之后的重命名失败, 用于检查回滚
+type failingRenameStore struct {
+	*store.MemoryStore
+}
+
+func (s failingRenameStore) Rename(transaction model.Transaction, path string, name string) error {
+	return errors.New("rename failed")
+}
+
+//batchMoveStore 不能整体移动文件夹, 通过 MoveAll 批量移动, refused 中的资源不能移动
+type batchMoveStore struct {
+	*store.MemoryStore
+	refused map[string]bool
+	batches [][]string
+}
+
+func (s *batchMoveStore) Move(transaction model.Transaction, path string, toParentPath string) error {
+	if s.refused[path] {
+		return store.ErrConflict
+	}
+	so, err := s.MemoryStore.StoredObject(transaction, path)
+	if err != nil {
+		return err
+	}
+	if so.IsFolder {
+		return store.ErrNotSupported
+	}
+	return s.MemoryStore.Move(transaction, path, toParentPath)
+}
+
+func (s *batchMoveStore) MoveAll(transaction model.Transaction, paths []string, toParentPath string) map[string]error {
+	s.batches = append(s.batches, paths)
+	failures := map[string]error{}
+	for _, path := range paths {
+		if err := s.Move(transaction, path, toParentPath); err != nil {
+			failures[path] = err
+		}
+	}
+	return failures
+}
+
+func TestParseDestinationHeader(t *testing.T) {
+	defer func(contextPath string) {
+		config.WebConf.ContextPath = contextPath
+	}(config.WebConf.ContextPath)
+	config.WebConf.ContextPath = "/dav"
+
+	tests := []struct {
+		destination string
+		path        string
+		status      int
+	}{
+		{"http://example.com/dav/a%20b/c.txt", "/a b/c.txt", 0},
+		{"/dav/a/./b/../c/", "/a/c", 0},
+		{"http://example.com/dav", "/", 0},
+		{"http://example.com/other/c.txt", "", http.StatusBadGateway},
+		{"/dav/../..", "", http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("MOVE", "/a.txt", nil)
+		r.Header.Set(DestinationKey, test.destination)
+		p, err := ParseDestinationHeader(w, r)
+		if (err != nil) != (test.status != 0) || p != test.path || (err != nil && w.Code != test.status) {
+			t.Errorf("destination %q: %q %v %d", test.destination, p, err, w.Code)
+		}
+	}
+}
+
+func TestDoMove(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/b"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/a/1.txt", "/a/2.txt", "/b/3.txt"} {
+		if _, err := memoryStore.SetResourceContent(transaction, file, strings.NewReader(file), "text/plain", -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	props := store.BuildMemoryPropertyStore()
+	color := model.Property{Name: xml.Name{Space: "urn:example", Local: "color"}, InnerXML: "red"}
+	if err := props.Patch("/a/1.txt", []store.PropertyPatch{{Props: []model.Property{color}}}); err != nil {
+		t.Fatal(err)
+	}
+	doMove := BuildDoMove(memoryStore, props, nil)
+
+	tests := []struct {
+		path        string
+		destination string
+		overwrite   string
+		status      int
+	}{
+		// 重命名
+		{"/a/1.txt", "/a/one.txt", "", http.StatusCreated},
+		// 移动
+		{"/a/one.txt", "/b/one.txt", "", http.StatusCreated},
+		// 移动并重命名
+		{"/a/2.txt", "/b/two.txt", "", http.StatusCreated},
+		{"/b/two.txt", "/b/3.txt", "F", http.StatusPreconditionFailed},
+		{"/b/two.txt", "/b/3.txt", "T", http.StatusNoContent},
+		{"/b/3.txt", "/missing/3.txt", "", http.StatusConflict},
+		{"/b", "/b/c", "", http.StatusForbidden},
+		{"/missing", "/b/x", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("MOVE", test.path, nil)
+		r.Header.Set(DestinationKey, "http://example.com"+test.destination)
+		if len(test.overwrite) > 0 {
+			r.Header.Set("Overwrite", test.overwrite)
+		}
+		doMove.Execute(transaction, w, r)
+		if w.Code != test.status {
+			t.Errorf("MOVE %s to %s: %d, want %d", test.path, test.destination, w.Code, test.status)
+		}
+	}
+	if content, err := memoryStore.ResourceContent(transaction, "/b/3.txt"); err != nil {
+		t.Errorf("overwritten destination: %v", err)
+	} else if data, _ := ioutil.ReadAll(content); string(data) != "/a/2.txt" {
+		t.Errorf("overwritten destination content %q", data)
+	}
+	if list, _ := props.Properties("/b/one.txt"); len(list) != 1 || list[0] != color {
+		t.Errorf("properties not moved: %v", list)
+	}
+
+	// 移动之后重命名失败, 资源回到原来的位置
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("MOVE", "/b/one.txt", nil)
+	r.Header.Set(DestinationKey, "/a/uno.txt")
+	BuildDoMove(failingRenameStore{memoryStore}, props, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("failed rename: %d", w.Code)
+	}
+	if _, err := memoryStore.StoredObject(transaction, "/b/one.txt"); err != nil {
+		t.Errorf("move not rolled back: %v", err)
+	}
+}
+
+func TestDoMoveMembers(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/a/sub", "/b", "/c"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/a/1.txt", "/a/sub/2.txt", "/b/3.txt", "/b/4.txt"} {
+		if _, err := memoryStore.SetResourceContent(transaction, file, strings.NewReader(file), "text/plain", -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	props := store.BuildMemoryPropertyStore()
+	color := model.Property{Name: xml.Name{Space: "urn:example", Local: "color"}, InnerXML: "red"}
+	for _, p := range []string{"/a/sub/2.txt", "/b/3.txt"} {
+		if err := props.Patch(p, []store.PropertyPatch{{Props: []model.Property{color}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batchStore := &batchMoveStore{MemoryStore: memoryStore, refused: map[string]bool{"/b/4.txt": true}}
+	doMove := BuildDoMove(batchStore, props, nil)
+
+	// 文件夹不能整体移动, 子资源逐个文件夹批量移动
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("MOVE", "/a", nil)
+	r.Header.Set(DestinationKey, "/c/a")
+	doMove.Execute(transaction, w, r)
+	if w.Code != http.StatusCreated || len(batchStore.batches) != 2 || len(batchStore.batches[0]) != 2 {
+		t.Errorf("move folder: %d, batches %v", w.Code, batchStore.batches)
+	}
+	for p, exists := range map[string]bool{"/a": false, "/c/a/1.txt": true, "/c/a/sub/2.txt": true, "/a/sub": false} {
+		if _, err := memoryStore.StoredObject(transaction, p); (err == nil) != exists {
+			t.Errorf("%s exists %v, want %v", p, err == nil, exists)
+		}
+	}
+	if list, _ := props.Properties("/c/a/sub/2.txt"); len(list) != 1 {
+		t.Errorf("properties not moved: %v", list)
+	}
+
+	// 部分子资源不能移动时列出失败的子资源, 已经移动的子资源保留在目标位置
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("MOVE", "/b", nil)
+	r.Header.Set(DestinationKey, "/c/b")
+	doMove.Execute(transaction, w, r)
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "<D:href>/b/4.txt</D:href><D:status>HTTP/1.1 409 Conflict</D:status>") ||
+		strings.Count(body, "<D:response>") != 1 {
+		t.Errorf("partial move: %d %s", w.Code, body)
+	}
+	for p, exists := range map[string]bool{"/b": true, "/b/4.txt": true, "/c/b/3.txt": true, "/b/3.txt": false} {
+		if _, err := memoryStore.StoredObject(transaction, p); (err == nil) != exists {
+			t.Errorf("%s exists %v, want %v", p, err == nil, exists)
+		}
+	}
+	if list, _ := props.Properties("/c/b/3.txt"); len(list) != 1 {
+		t.Errorf("properties not moved: %v", list)
+	}
+}
+
+func TestDoMoveOverwriteLocked(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/b", "/c"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/a/1.txt", "/b/2.txt", "/b/3.txt", "/c/4.txt"} {
+		if _, err := memoryStore.SetResourceContent(transaction, file, strings.NewReader(file), "text/plain", -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resourceLocks := locking.Build()
+	if _, ok := resourceLocks.Lock(transaction, "/b/2.txt", "other", true, 0, 600, false); !ok {
+		t.Fatal("lock /b/2.txt")
+	}
+	lo, ok := resourceLocks.Lock(transaction, "/c", "me", true, locking.DepthInfinity, 600, false)
+	if !ok {
+		t.Fatal("lock /c")
+	}
+	doMove := BuildDoMove(memoryStore, nil, resourceLocks)
+
+	// 目标中有被其他客户端锁定的子资源
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("MOVE", "/a", nil)
+	r.Header.Set(DestinationKey, "/b")
+	r.Header.Set("Overwrite", "T")
+	doMove.Execute(transaction, w, r)
+	if body := w.Body.String(); w.Code != http.StatusMultiStatus ||
+		!strings.Contains(body, "<D:href>/b/2.txt</D:href><D:status>HTTP/1.1 423 Locked</D:status>") {
+		t.Errorf("locked member: %d %s", w.Code, body)
+	}
+	if _, err := memoryStore.StoredObject(transaction, "/a/1.txt"); err != nil {
+		t.Errorf("source moved: %v", err)
+	}
+
+	// 覆盖提交了 lock token 的目标后, 目标上的锁随之删除
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("MOVE", "/a", nil)
+	r.Header.Set(DestinationKey, "/c")
+	r.Header.Set("Overwrite", "T")
+	r.Header.Set("If", "<http://example.com/c> (<"+LockTokenPrefix+lo.Id+">)")
+	doMove.Execute(transaction, w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("overwrite locked destination: %d %s", w.Code, w.Body.String())
+	}
+	if locks := resourceLocks.LockedObjectsByPath(transaction, "/c"); len(locks) != 0 {
+		t.Errorf("locks left on overwritten destination: %v", locks)
+	}
+}
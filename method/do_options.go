@@ -0,0 +1,88 @@
+package method
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DefaultDavClasses 默认支持的 compliance class
+const DefaultDavClasses = "1, 2"
+
+//DoOptions 返回服务器支持的 compliance class 和资源允许的方法
+type DoOptions struct {
+	store         store.Store
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoOptions resourceLocks 为 nil 时表示不支持锁, 不返回 class 2 和 LOCK/UNLOCK
+func BuildDoOptions(webdavStore store.Store, resourceLocks locking.IResourceLocks) *DoOptions {
+	return &DoOptions{
+		store:         webdavStore,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoOptions) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	so, err := d.store.StoredObject(transaction, p)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if err != nil {
+		so = nil
+	}
+
+	w.Header().Set("DAV", d.davClasses())
+	w.Header().Set("Allow", strings.Join(d.allow(p, so), ", "))
+	// Office 和 Windows 需要这个头才会使用 WebDAV 编辑
+	w.Header().Set("MS-Author-Via", "DAV")
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+}
+
+//davClasses 返回配置的 compliance class, 不支持锁时去掉 class 2
+func (d *DoOptions) davClasses() string {
+	classes := config.WebConf.DavClasses
+	if len(strings.TrimSpace(classes)) <= 0 {
+		classes = DefaultDavClasses
+	}
+	var result []string
+	for _, class := range strings.Split(classes, ",") {
+		class = strings.TrimSpace(class)
+		if len(class) <= 0 || (class == "2" && d.resourceLocks == nil) {
+			continue
+		}
+		result = append(result, class)
+	}
+	return strings.Join(result, ", ")
+}
+
+//allow 返回 p 允许的方法, so 为 nil 表示资源不存在
+func (d *DoOptions) allow(p string, so *model.StoredObject) []string {
+	var methods []string
+	switch {
+	case so == nil:
+		methods = []string{"OPTIONS", "MKCOL", "PUT"}
+	case len(model.BuildPathInfo(p).Name) <= 0:
+		// 根文件夹不能删除、移动或复制
+		methods = []string{"OPTIONS", "GET", "HEAD", "PROPFIND", "PROPPATCH"}
+	case so.IsFolder:
+		methods = []string{"OPTIONS", "GET", "HEAD", "DELETE", "PROPFIND", "PROPPATCH", "COPY", "MOVE"}
+	default:
+		methods = []string{"OPTIONS", "GET", "HEAD", "PUT", "DELETE", "PROPFIND", "PROPPATCH", "COPY", "MOVE"}
+	}
+	if d.resourceLocks == nil {
+		return methods
+	}
+	if so == nil {
+		// 可以锁定不存在的资源, 但没有锁可以解除
+		return append(methods, "LOCK")
+	}
+	return append(methods, "LOCK", "UNLOCK")
+}
@@ -0,0 +1,59 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestDoOptions(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if err := memoryStore.CreateFolder(transaction, "/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memoryStore.SetResourceContent(transaction, "/docs/a.txt", strings.NewReader("a"), "text/plain", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		locks bool
+		path  string
+		dav   string
+		allow string
+	}{
+		{true, "/docs/a.txt", "1, 2", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, COPY, MOVE, LOCK, UNLOCK"},
+		{true, "/docs", "1, 2", "OPTIONS, GET, HEAD, DELETE, PROPFIND, PROPPATCH, COPY, MOVE, LOCK, UNLOCK"},
+		{true, "/missing", "1, 2", "OPTIONS, MKCOL, PUT, LOCK"},
+		{true, "/", "1, 2", "OPTIONS, GET, HEAD, PROPFIND, PROPPATCH, LOCK, UNLOCK"},
+		{false, "/docs", "1", "OPTIONS, GET, HEAD, DELETE, PROPFIND, PROPPATCH, COPY, MOVE"},
+	}
+	for _, test := range tests {
+		doOptions := BuildDoOptions(memoryStore, nil)
+		if test.locks {
+			doOptions = BuildDoOptions(memoryStore, &fakeLocks{})
+		}
+		w := httptest.NewRecorder()
+		doOptions.Execute(transaction, w, httptest.NewRequest("OPTIONS", test.path, nil))
+		if w.Code != http.StatusOK || w.Header().Get("DAV") != test.dav || w.Header().Get("Allow") != test.allow {
+			t.Errorf("OPTIONS %s: %d DAV %q Allow %q", test.path, w.Code, w.Header().Get("DAV"), w.Header().Get("Allow"))
+		}
+		if w.Header().Get("MS-Author-Via") != "DAV" {
+			t.Errorf("OPTIONS %s: MS-Author-Via %q", test.path, w.Header().Get("MS-Author-Via"))
+		}
+	}
+
+	defer func(classes string) {
+		config.WebConf.DavClasses = classes
+	}(config.WebConf.DavClasses)
+	config.WebConf.DavClasses = "1,2,3"
+	w := httptest.NewRecorder()
+	BuildDoOptions(memoryStore, nil).Execute(transaction, w, httptest.NewRequest("OPTIONS", "/", nil))
+	if w.Header().Get("DAV") != "1, 3" {
+		t.Errorf("configured DAV %q", w.Header().Get("DAV"))
+	}
+}
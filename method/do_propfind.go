@@ -0,0 +1,335 @@
+package method
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+const (
+	findAllProp = iota
+	findPropName
+	findByProperty
+)
+
+//LiveProps 服务器维护的属性, allprop 和 propname 按此顺序返回
+var LiveProps = []xml.Name{
+	{Space: DavNamespace, Local: "creationdate"},
+	{Space: DavNamespace, Local: "displayname"},
+	{Space: DavNamespace, Local: "getcontentlength"},
+	{Space: DavNamespace, Local: "getcontenttype"},
+	{Space: DavNamespace, Local: "getetag"},
+	{Space: DavNamespace, Local: "getlastmodified"},
+	{Space: DavNamespace, Local: "lockdiscovery"},
+	{Space: DavNamespace, Local: "resourcetype"},
+	{Space: DavNamespace, Local: "supportedlock"},
+}
+
+var getetag = xml.Name{Space: DavNamespace, Local: "getetag"}
+
+//DoPropfind 以 multistatus 返回资源及其子资源的属性
+type DoPropfind struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoPropfind props 为 nil 时只返回活属性
+func BuildDoPropfind(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoPropfind {
+	return &DoPropfind{store: webdavStore, props: props, resourceLocks: resourceLocks}
+}
+
+//propfind 请求体, 请求体为空时等同于 allprop
+type propfind struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     propNames `xml:"DAV: prop"`
+	findType int
+}
+
+//propNames 元素下所有子元素的名称
+type propNames []xml.Name
+
+func (n *propNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			*n = append(*n, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (d *DoPropfind) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	depth := Depth(r)
+	if depth == Infinity && !config.WebConf.PropfindInfiniteDepth {
+		WriteConditionError(w, http.StatusForbidden, "propfind-finite-depth")
+		return
+	}
+	find, err := parsePropfind(r.Body)
+	if err != nil {
+		logrus.Debugf("PROPFIND %s bad request: %v", p, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	so, err := d.store.StoredObject(transaction, p)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+
+	ms := buildMultistatus(w)
+	if err := d.walk(transaction, ms, find, p, so, depth); err != nil {
+		// 已经开始返回 207, 只能记录错误
+		logrus.Errorf("PROPFIND %s failed: %v", p, err)
+	}
+	if err := ms.close(); err != nil {
+		logrus.Errorf("PROPFIND %s failed: %v", p, err)
+	}
+}
+
+//walk 写入 p 的属性, 文件夹按 depth 继续写入子资源
+func (d *DoPropfind) walk(transaction model.Transaction, ms *multistatus, find *propfind,
+	p string, so *model.StoredObject, depth int) error {
+	OverlayTimes(d.props, p, so)
+	if err := ms.writeResponse(Href(p, so.IsFolder), http.StatusOK, d.propstats(transaction, find, p, so)); err != nil {
+		return err
+	}
+	if !so.IsFolder || depth == 0 {
+		return nil
+	}
+	if depth != Infinity {
+		depth--
+	}
+	if walker, ok := d.store.(store.ChildWalker); ok {
+		return walker.WalkChildren(transaction, p, func(name string, child *model.StoredObject) error {
+			return d.walk(transaction, ms, find, path.Join(p, name), child, depth)
+		})
+	}
+	names, err := d.store.ChildrenNames(transaction, p)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		childPath := path.Join(p, name)
+		child, err := d.store.StoredObject(transaction, childPath)
+		if errors.Is(err, store.ErrNotFound) {
+			// 列出之后被删除
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.walk(transaction, ms, find, childPath, child, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//propstats 按请求类型生成 p 的属性, 找不到的属性以 404 返回
+func (d *DoPropfind) propstats(transaction model.Transaction, find *propfind, p string, so *model.StoredObject) []Propstat {
+	found := Propstat{Status: http.StatusOK}
+	missing := Propstat{Status: http.StatusNotFound}
+	deadProps, err := d.deadProps(p)
+	if err != nil {
+		logrus.Errorf("PROPFIND %s dead properties: %v", p, err)
+	}
+	switch find.findType {
+	case findAllProp, findPropName:
+		for _, name := range LiveProps {
+			value, ok := d.liveProp(transaction, name, p, so)
+			if !ok {
+				continue
+			}
+			if find.findType == findPropName {
+				value = ""
+			}
+			found.Props = append(found.Props, model.Property{Name: name, InnerXML: value})
+		}
+		for _, prop := range deadProps {
+			if isWin32Time(prop.Name) {
+				continue
+			}
+			if find.findType == findPropName {
+				prop.InnerXML = ""
+			}
+			found.Props = append(found.Props, prop)
+		}
+	case findByProperty:
+	names:
+		for _, name := range find.Prop {
+			if name == getetag {
+				// 文件夹的 ETag 需要列出子资源, 只在明确请求时计算
+				FolderHash(transaction, d.store, p, so)
+			}
+			if value, ok := d.liveProp(transaction, name, p, so); ok {
+				found.Props = append(found.Props, model.Property{Name: name, InnerXML: value})
+				continue
+			}
+			for _, prop := range deadProps {
+				if prop.Name == name && !isWin32Time(name) {
+					found.Props = append(found.Props, prop)
+					continue names
+				}
+			}
+			missing.Props = append(missing.Props, model.Property{Name: name})
+		}
+	}
+	var propstats []Propstat
+	for _, ps := range []Propstat{found, missing} {
+		if len(ps.Props) > 0 {
+			propstats = append(propstats, ps)
+		}
+	}
+	return propstats
+}
+
+func (d *DoPropfind) deadProps(p string) ([]model.Property, error) {
+	if d.props == nil {
+		return nil, nil
+	}
+	return d.props.Properties(p)
+}
+
+//liveProp 返回活属性 name 的内容, 资源没有该属性时返回 false
+func (d *DoPropfind) liveProp(transaction model.Transaction, name xml.Name, p string, so *model.StoredObject) (string, bool) {
+	switch name {
+	case Win32LastModifiedTime:
+		return so.LastModified.UTC().Format(Rfc1123), true
+	case Win32CreationTime:
+		return so.CreationDate.UTC().Format(Rfc1123), true
+	}
+	if name.Space != DavNamespace {
+		return "", false
+	}
+	switch name.Local {
+	case "creationdate":
+		return so.CreationDate.UTC().Format(Iso8601), true
+	case "displayname":
+		return EscapeXML(path.Base(p)), p != "/"
+	case "getcontentlength":
+		return strconv.FormatInt(so.ContentLength, 10), !so.IsFolder
+	case "getcontenttype":
+		return EscapeXML(ContentType(p, so)), !so.IsFolder
+	case "getetag":
+		// 文件夹的 ETag 与 GET 一样由子资源计算, 没有计算时不返回, 不使用所有文件夹相同的占位值
+		return EscapeXML(ETag(so)), !so.IsFolder || len(so.ContentHash) > 0
+	case "getlastmodified":
+		return so.LastModified.UTC().Format(Rfc1123), true
+	case "lockdiscovery":
+		return LockDiscovery(transaction, d.resourceLocks, p), true
+	case "resourcetype":
+		if so.IsFolder {
+			return "<D:collection/>", true
+		}
+		return "", true
+	case "supportedlock":
+		return SupportedLock(d.resourceLocks), true
+	}
+	return "", false
+}
+
+//parsePropfind 解析请求体, 请求体为空时返回 allprop
+func parsePropfind(body io.Reader) (*propfind, error) {
+	find := &propfind{}
+	if body == nil {
+		return find, nil
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) <= 0 {
+		return find, nil
+	}
+	if err := xml.Unmarshal(data, find); err != nil {
+		return nil, err
+	}
+	switch {
+	case find.AllProp != nil:
+		find.findType = findAllProp
+	case find.PropName != nil:
+		find.findType = findPropName
+	case find.Prop != nil:
+		find.findType = findByProperty
+	default:
+		return nil, errors.New("propfind without allprop, propname or prop")
+	}
+	return find, nil
+}
+
+//SupportedLock 返回 supportedlock 属性的内容, 未启用锁时为空
+func SupportedLock(resourceLocks locking.IResourceLocks) string {
+	if resourceLocks == nil {
+		return ""
+	}
+	return "<D:lockentry><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>" +
+		"<D:lockentry><D:lockscope><D:shared/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>"
+}
+
+//LockDiscovery 返回 lockdiscovery 属性的内容, 列出作用于 p 的锁, 包括从上级文件夹继承的锁
+func LockDiscovery(transaction model.Transaction, resourceLocks locking.IResourceLocks, p string) string {
+	if resourceLocks == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, lo := range CoveringLocks(transaction, resourceLocks, p) {
+		b.WriteString(ActiveLock(lo))
+	}
+	return b.String()
+}
+
+//ActiveLock 返回锁 lo 的 activelock 元素
+func ActiveLock(lo locking.LockedObject) string {
+	scope := "shared"
+	if lo.Exclusive {
+		scope = "exclusive"
+	}
+	depth := "infinity"
+	if lo.LockDepth != locking.DepthInfinity {
+		depth = strconv.Itoa(int(lo.LockDepth))
+	}
+	timeout := "Infinite"
+	if lo.ExpireAt > 0 {
+		seconds := lo.ExpireAt - time.Now().Unix()
+		if seconds < 0 {
+			seconds = 0
+		}
+		timeout = "Second-" + strconv.FormatInt(seconds, 10)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<D:activelock><D:locktype><D:write/></D:locktype><D:lockscope><D:%s/></D:lockscope><D:depth>%s</D:depth>",
+		scope, depth)
+	// owner 是 LOCK 请求中原样保存的 XML
+	if len(lo.Owner) > 0 {
+		fmt.Fprintf(&b, "<D:owner>%s</D:owner>", lo.Owner)
+	}
+	fmt.Fprintf(&b, "<D:timeout>%s</D:timeout><D:locktoken><D:href>%s%s</D:href></D:locktoken>",
+		timeout, LockTokenPrefix, EscapeXML(lo.Id))
+	fmt.Fprintf(&b, "<D:lockroot><D:href>%s</D:href></D:lockroot></D:activelock>", EscapeXML(Href(lo.Path, false)))
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestDoPropfind(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if err := memoryStore.CreateFolder(transaction, "/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memoryStore.SetResourceContent(transaction, "/docs/a b.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	doPropfind := BuildDoPropfind(memoryStore, nil, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PROPFIND", "/docs", nil)
+	r.Header.Set("Depth", "1")
+	doPropfind.Execute(transaction, w, r)
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "<D:href>/docs/</D:href>") ||
+		!strings.Contains(body, "<D:href>/docs/a%20b.txt</D:href>") || !strings.Contains(body, "<D:getcontentlength>5</D:getcontentlength>") ||
+		!strings.Contains(body, "<D:resourcetype><D:collection/></D:resourcetype>") {
+		t.Errorf("allprop: %d %s", w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PROPFIND", "/docs/a%20b.txt", strings.NewReader(
+		`<?xml version="1.0"?><propfind xmlns="DAV:"><prop><getetag/><x xmlns="urn:example"/></prop></propfind>`))
+	r.Header.Set("Depth", "0")
+	doPropfind.Execute(transaction, w, r)
+	body = w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "<D:getetag>") ||
+		!strings.Contains(body, `<R:x xmlns:R="urn:example"/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>`) {
+		t.Errorf("named prop: %d %s", w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PROPFIND", "/docs", strings.NewReader(`<propfind xmlns="DAV:"><propname/></propfind>`))
+	r.Header.Set("Depth", "0")
+	doPropfind.Execute(transaction, w, r)
+	if body = w.Body.String(); !strings.Contains(body, "<D:creationdate/>") || strings.Contains(body, "getcontentlength") {
+		t.Errorf("propname: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	doPropfind.Execute(transaction, w, httptest.NewRequest("PROPFIND", "/docs", nil))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "propfind-finite-depth") {
+		t.Errorf("infinite depth: %d %s", w.Code, w.Body.String())
+	}
+}
+
+//countingHasher 记录计算文件夹 ETag 的次数
+type countingHasher struct {
+	*store.MemoryStore
+	hashed int
+}
+
+func (s *countingHasher) ChildrenHash(transaction model.Transaction, folderPath string) (string, error) {
+	s.hashed++
+	return "hash" + folderPath, nil
+}
+
+func TestDoPropfindFolderETag(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/docs", "/docs/a", "/docs/b"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hasher := &countingHasher{MemoryStore: memoryStore}
+	doPropfind := BuildDoPropfind(hasher, nil, nil)
+
+	// allprop 不为每个子文件夹列出子资源, 也不返回所有文件夹相同的占位 ETag
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PROPFIND", "/docs", nil)
+	r.Header.Set("Depth", "1")
+	doPropfind.Execute(transaction, w, r)
+	if body := w.Body.String(); w.Code != http.StatusMultiStatus || strings.Contains(body, "getetag") || hasher.hashed != 0 {
+		t.Errorf("allprop: %d, hashed %d, %s", w.Code, hasher.hashed, body)
+	}
+
+	// 明确请求时与 GET 返回相同的 ETag
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PROPFIND", "/docs", strings.NewReader(`<propfind xmlns="DAV:"><prop><getetag/></prop></propfind>`))
+	r.Header.Set("Depth", "1")
+	doPropfind.Execute(transaction, w, r)
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || hasher.hashed != 3 {
+		t.Errorf("named getetag: %d, hashed %d", w.Code, hasher.hashed)
+	}
+	for _, p := range []string{"/docs", "/docs/a", "/docs/b"} {
+		w = httptest.NewRecorder()
+		BuildDoGet(hasher, nil, nil).Execute(transaction, w, httptest.NewRequest(http.MethodHead, p, nil))
+		etag := w.Header().Get("ETag")
+		if len(etag) <= 0 || !strings.Contains(body, "<D:getetag>"+EscapeXML(etag)+"</D:getetag>") {
+			t.Errorf("%s: GET etag %s, propfind %s", p, etag, body)
+		}
+	}
+}
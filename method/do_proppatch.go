@@ -0,0 +1,211 @@
+package method
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoProppatch 设置或删除资源的 dead property, 所有操作全部成功或者全部不生效
+type DoProppatch struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoProppatch props 为 nil 时不能设置 dead property, 只能修改后端支持的 Win32 时间
+func BuildDoProppatch(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoProppatch {
+	return &DoProppatch{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+//propertyUpdate PROPPATCH 请求体, set 和 remove 按出现顺序执行
+type propertyUpdate struct {
+	XMLName    xml.Name    `xml:"DAV: propertyupdate"`
+	Operations []setRemove `xml:",any"`
+}
+
+type setRemove struct {
+	XMLName xml.Name
+	Prop    struct {
+		Props []rawProperty `xml:",any"`
+	} `xml:"DAV: prop"`
+}
+
+//rawProperty 请求中的一个属性, 值连同其中用到的命名空间一起保存
+type rawProperty struct {
+	XMLName  xml.Name
+	InnerXML string
+}
+
+func (p *rawProperty) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var content innerXML
+	if err := content.UnmarshalXML(d, start); err != nil {
+		return err
+	}
+	p.XMLName = start.Name
+	p.InnerXML = string(content)
+	return nil
+}
+
+func (d *DoProppatch) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	if !CheckLocks(transaction, r, w, d.resourceLocks, p) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+	so, err := d.store.StoredObject(transaction, p)
+	if err != nil {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	patches, err := parseProppatch(r)
+	if err != nil {
+		logrus.Debugf("PROPPATCH %s bad request: %v", p, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ms := buildMultistatus(w)
+	if err := ms.writeResponse(Href(p, so.IsFolder), http.StatusOK, d.patch(transaction, p, so, patches)); err != nil {
+		logrus.Errorf("PROPPATCH %s failed: %v", p, err)
+	}
+	if err := ms.close(); err != nil {
+		logrus.Errorf("PROPPATCH %s failed: %v", p, err)
+	}
+}
+
+//patch 应用 patches 并返回每个属性的状态, 有属性不能修改时全部不生效
+//Win32 时间由 store.TimeSetter 保存, 后端不支持时作为 dead property 记录, 保存 dead property 失败时恢复原来的时间
+func (d *DoProppatch) patch(transaction model.Transaction, p string, so *model.StoredObject, patches []store.PropertyPatch) []Propstat {
+	setter, canSetTimes := d.store.(store.TimeSetter)
+	forbidden := Propstat{Status: http.StatusForbidden}
+	conflict := Propstat{Status: http.StatusConflict}
+	dependency := Propstat{Status: http.StatusFailedDependency}
+	var lastModified, creationDate time.Time
+	var deadPatches []store.PropertyPatch
+	for _, patch := range patches {
+		dead := store.PropertyPatch{Remove: patch.Remove}
+		for _, prop := range patch.Props {
+			name := model.Property{Name: prop.Name}
+			if isLiveProp(prop.Name) {
+				forbidden.Props = append(forbidden.Props, name)
+				continue
+			}
+			if isWin32Time(prop.Name) && !patch.Remove {
+				t, err := http.ParseTime(strings.TrimSpace(prop.InnerXML))
+				if err != nil {
+					conflict.Props = append(conflict.Props, name)
+					continue
+				}
+				if prop.Name == Win32LastModifiedTime {
+					lastModified = t
+				} else {
+					creationDate = t
+				}
+			}
+			if isWin32Time(prop.Name) && !patch.Remove && canSetTimes {
+				dependency.Props = append(dependency.Props, name)
+				continue
+			}
+			// 没有 PropertyStore 时不能保存 dead property
+			if d.props == nil {
+				forbidden.Props = append(forbidden.Props, name)
+				continue
+			}
+			dependency.Props = append(dependency.Props, name)
+			dead.Props = append(dead.Props, prop)
+		}
+		if len(dead.Props) > 0 {
+			deadPatches = append(deadPatches, dead)
+		}
+	}
+
+	if len(forbidden.Props) > 0 || len(conflict.Props) > 0 {
+		var propstats []Propstat
+		for _, ps := range []Propstat{forbidden, conflict, dependency} {
+			if len(ps.Props) > 0 {
+				propstats = append(propstats, ps)
+			}
+		}
+		return propstats
+	}
+	ok := Propstat{Props: dependency.Props, Status: http.StatusOK}
+	var err error
+	timesSet := false
+	if canSetTimes && (!lastModified.IsZero() || !creationDate.IsZero()) {
+		err = setter.SetTimes(transaction, p, lastModified, creationDate)
+		timesSet = err == nil
+	}
+	if err == nil && len(deadPatches) > 0 {
+		err = d.props.Patch(p, deadPatches)
+		if err != nil && timesSet {
+			d.restoreTimes(transaction, setter, p, so, lastModified, creationDate)
+		}
+	}
+	if err != nil {
+		logrus.Errorf("PROPPATCH %s failed: %v", p, err)
+		ok.Status = StoreErrorStatus(err)
+	}
+	return []Propstat{ok}
+}
+
+func parseProppatch(r *http.Request) ([]store.PropertyPatch, error) {
+	var update propertyUpdate
+	if err := xml.NewDecoder(r.Body).Decode(&update); err != nil {
+		return nil, err
+	}
+	var patches []store.PropertyPatch
+	for _, operation := range update.Operations {
+		if operation.XMLName.Space != DavNamespace ||
+			(operation.XMLName.Local != "set" && operation.XMLName.Local != "remove") {
+			return nil, errors.New("unexpected element " + operation.XMLName.Local + " in propertyupdate")
+		}
+		patch := store.PropertyPatch{Remove: operation.XMLName.Local == "remove"}
+		for _, prop := range operation.Prop.Props {
+			property := model.Property{Name: prop.XMLName}
+			if !patch.Remove {
+				property.InnerXML = prop.InnerXML
+			}
+			patch.Props = append(patch.Props, property)
+		}
+		patches = append(patches, patch)
+	}
+	if len(patches) <= 0 {
+		return nil, errors.New("empty propertyupdate")
+	}
+	return patches, nil
+}
+
+func isLiveProp(name xml.Name) bool {
+	for _, live := range LiveProps {
+		if live == name {
+			return true
+		}
+	}
+	return false
+}
+
+//restoreTimes 恢复 SetTimes 修改过的时间
+func (d *DoProppatch) restoreTimes(transaction model.Transaction, setter store.TimeSetter, p string,
+	so *model.StoredObject, lastModified time.Time, creationDate time.Time) {
+	var oldLastModified, oldCreationDate time.Time
+	if !lastModified.IsZero() {
+		oldLastModified = so.LastModified
+	}
+	if !creationDate.IsZero() {
+		oldCreationDate = so.CreationDate
+	}
+	if err := setter.SetTimes(transaction, p, oldLastModified, oldCreationDate); err != nil {
+		logrus.Errorf("PROPPATCH %s restore times: %v", p, err)
+	}
+}
@@ -0,0 +1,196 @@
+package method
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestDoProppatch(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	props := store.BuildMemoryPropertyStore()
+	doProppatch := BuildDoProppatch(memoryStore, props, nil)
+	doPropfind := BuildDoPropfind(memoryStore, props, nil)
+
+	w := httptest.NewRecorder()
+	doProppatch.Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(
+		`<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:schemas-microsoft-com:">`+
+			`<D:set><D:prop><Z:Win32FileAttributes>00000020</Z:Win32FileAttributes><Z:Tag>x</Z:Tag></D:prop></D:set>`+
+			`<D:remove><D:prop><Z:Tag/></D:prop></D:remove></D:propertyupdate>`)))
+	if w.Code != http.StatusMultiStatus || strings.Count(w.Body.String(), "HTTP/1.1 200 OK") != 1 {
+		t.Errorf("set: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PROPFIND", "/a.txt", nil)
+	r.Header.Set("Depth", "0")
+	doPropfind.Execute(transaction, w, r)
+	if body := w.Body.String(); !strings.Contains(body, `<R:Win32FileAttributes xmlns:R="urn:schemas-microsoft-com:">00000020</R:Win32FileAttributes>`) ||
+		strings.Contains(body, "Tag") {
+		t.Errorf("propfind: %s", body)
+	}
+
+	// 活属性不能修改, 整个请求都不生效
+	w = httptest.NewRecorder()
+	doProppatch.Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(
+		`<propertyupdate xmlns="DAV:"><set><prop><getetag>x</getetag><color xmlns="urn:example">red</color></prop></set></propertyupdate>`)))
+	if body := w.Body.String(); !strings.Contains(body, "403 Forbidden") || !strings.Contains(body, "424 Failed Dependency") {
+		t.Errorf("protected: %s", body)
+	}
+	if list, _ := props.Properties("/a.txt"); len(list) != 1 {
+		t.Errorf("properties after failed patch: %v", list)
+	}
+}
+
+//timelessStore 隐藏 MemoryStore 的 SetTimes, 模拟不能保存时间的后端
+type timelessStore struct {
+	store.Store
+}
+
+func TestDoProppatchWin32Times(t *testing.T) {
+	var transaction model.Transaction
+	const win32 = `<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:schemas-microsoft-com:"><D:set><D:prop>` +
+		`<Z:Win32CreationTime>Mon, 01 Jan 2018 08:00:00 GMT</Z:Win32CreationTime>` +
+		`<Z:Win32LastModifiedTime>Tue, 02 Jan 2018 09:30:00 GMT</Z:Win32LastModifiedTime>` +
+		`</D:prop></D:set></D:propertyupdate>`
+	for _, backend := range []string{"setter", "server"} {
+		memoryStore := store.BuildMemoryStore()
+		if _, err := memoryStore.SetResourceContent(transaction, "/a.jpg", strings.NewReader("jpg"), "image/jpeg", 3); err != nil {
+			t.Fatal(err)
+		}
+		var webdavStore store.Store = memoryStore
+		if backend == "server" {
+			webdavStore = timelessStore{memoryStore}
+		}
+		props := store.BuildMemoryPropertyStore()
+
+		w := httptest.NewRecorder()
+		BuildDoProppatch(webdavStore, props, nil).Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.jpg", strings.NewReader(win32)))
+		if strings.Count(w.Body.String(), "HTTP/1.1 200 OK") != 1 {
+			t.Errorf("%s: proppatch %s", backend, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest("PROPFIND", "/a.jpg", nil)
+		r.Header.Set("Depth", "0")
+		BuildDoPropfind(webdavStore, props, nil).Execute(transaction, w, r)
+		body := w.Body.String()
+		if !strings.Contains(body, "<D:getlastmodified>Tue, 02 Jan 2018 09:30:00 GMT</D:getlastmodified>") ||
+			!strings.Contains(body, "<D:creationdate>2018-01-01T08:00:00Z</D:creationdate>") {
+			t.Errorf("%s: propfind %s", backend, body)
+		}
+
+		w = httptest.NewRecorder()
+		BuildDoGet(webdavStore, props, nil).Execute(transaction, w, httptest.NewRequest(http.MethodGet, "/a.jpg", nil))
+		if w.Header().Get("Last-Modified") != "Tue, 02 Jan 2018 09:30:00 GMT" {
+			t.Errorf("%s: get %v", backend, w.Header())
+		}
+
+		// 上传新内容后不再使用 PROPPATCH 设置的时间
+		w = httptest.NewRecorder()
+		BuildDoPut(webdavStore, props, nil).Execute(transaction, w, httptest.NewRequest(http.MethodPut, "/a.jpg", strings.NewReader("png")))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: put %d", backend, w.Code)
+		}
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/a.jpg", nil)
+		r.Header.Set("If-Modified-Since", "Tue, 02 Jan 2018 09:30:00 GMT")
+		BuildDoGet(webdavStore, props, nil).Execute(transaction, w, r)
+		if w.Code != http.StatusOK || w.Body.String() != "png" || strings.Contains(w.Header().Get("Last-Modified"), "2018") {
+			t.Errorf("%s: get after put %d %v", backend, w.Code, w.Header())
+		}
+	}
+}
+
+func TestDoProppatchWithoutPropertyStore(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	const win32 = `<D:Win32LastModifiedTime xmlns:D="urn:schemas-microsoft-com:">Tue, 02 Jan 2018 09:30:00 GMT</D:Win32LastModifiedTime>`
+	tests := []struct {
+		webdavStore store.Store
+		props       string
+		want        []string
+	}{
+		{memoryStore, win32, []string{"200 OK"}},
+		{memoryStore, win32 + `<Z:Tag xmlns:Z="urn:example">x</Z:Tag>`, []string{"403 Forbidden", "424 Failed Dependency"}},
+		// 后端不能保存时间时 Win32 时间也只能作为 dead property 保存
+		{timelessStore{memoryStore}, win32, []string{"403 Forbidden"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		body := `<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop>` + test.props + `</D:prop></D:set></D:propertyupdate>`
+		BuildDoProppatch(test.webdavStore, nil, nil).Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(body)))
+		for _, status := range test.want {
+			if !strings.Contains(w.Body.String(), status) {
+				t.Errorf("%s: %s", test.props, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestDoProppatchNamespaces(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	props := store.BuildMemoryPropertyStore()
+
+	// 值中的前缀在上级元素中声明
+	w := httptest.NewRecorder()
+	BuildDoProppatch(memoryStore, props, nil).Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(
+		`<D:propertyupdate xmlns:D="DAV:" xmlns:E="urn:example" xmlns:F="urn:other"><D:set><D:prop>`+
+			`<E:authors><E:author F:role="lead">alice</E:author><D:href>x</D:href></E:authors></D:prop></D:set></D:propertyupdate>`)))
+	if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "HTTP/1.1 200 OK") {
+		t.Fatalf("set: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PROPFIND", "/a.txt", nil)
+	r.Header.Set("Depth", "0")
+	BuildDoPropfind(memoryStore, props, nil).Execute(transaction, w, r)
+	if body := w.Body.String(); !strings.Contains(body, `<R:authors xmlns:R="urn:example">`+
+		`<author xmlns="urn:example" xmlns:a0="urn:other" a0:role="lead">alice</author><href xmlns="DAV:">x</href></R:authors>`) {
+		t.Errorf("propfind: %s", body)
+	}
+}
+
+//failingProps 保存 dead property 总是失败
+type failingProps struct {
+	store.PropertyStore
+}
+
+func (p failingProps) Patch(path string, patches []store.PropertyPatch) error {
+	return errors.New("patch failed")
+}
+
+func TestDoProppatchRestoresTimes(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := memoryStore.StoredObject(transaction, "/a.txt")
+
+	w := httptest.NewRecorder()
+	BuildDoProppatch(memoryStore, failingProps{store.BuildMemoryPropertyStore()}, nil).Execute(transaction, w, httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(
+		`<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:schemas-microsoft-com:"><D:set><D:prop>`+
+			`<Z:Win32LastModifiedTime>Mon, 01 Jan 2018 08:00:00 GMT</Z:Win32LastModifiedTime><Z:Tag>x</Z:Tag></D:prop></D:set></D:propertyupdate>`)))
+	if body := w.Body.String(); !strings.Contains(body, "500 Internal Server Error") {
+		t.Errorf("failed patch: %d %s", w.Code, body)
+	}
+	if after, _ := memoryStore.StoredObject(transaction, "/a.txt"); !after.LastModified.Equal(before.LastModified) {
+		t.Errorf("last modified %v, was %v", after.LastModified, before.LastModified)
+	}
+}
@@ -0,0 +1,69 @@
+package method
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//DoPut 上传文件内容, 文件不存在时创建
+type DoPut struct {
+	store         store.Store
+	props         store.PropertyStore
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoPut props 为 nil 时不处理服务器记录的 Win32 时间
+func BuildDoPut(webdavStore store.Store, props store.PropertyStore, resourceLocks locking.IResourceLocks) *DoPut {
+	return &DoPut{
+		store:         webdavStore,
+		props:         props,
+		resourceLocks: resourceLocks,
+	}
+}
+
+func (d *DoPut) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	path := CleanPath(RelativePath(r))
+	parentPath := model.BuildPathInfo(path).ParentPath
+	if !CheckLocks(transaction, r, w, d.resourceLocks, parentPath) ||
+		!CheckLocks(transaction, r, w, d.resourceLocks, path) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	so, err := d.store.StoredObject(transaction, path)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	if !CheckPreconditions(transaction, d.store, d.resourceLocks, w, r, path, so) {
+		return
+	}
+	if so != nil && so.IsFolder {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := d.store.SetResourceContent(transaction, path, r.Body, r.Header.Get("Content-Type"), r.ContentLength); err != nil {
+		logrus.Errorf("PUT %s failed: %v", path, err)
+		w.WriteHeader(StoreErrorStatus(err))
+		return
+	}
+	// 内容已经改变, 服务器记录的 Win32 时间不再有效
+	if d.props != nil {
+		err := d.props.Patch(path, []store.PropertyPatch{{Remove: true, Props: []model.Property{
+			{Name: Win32LastModifiedTime}, {Name: Win32CreationTime},
+		}}})
+		if err != nil {
+			logrus.Errorf("PUT %s properties: %v", path, err)
+		}
+	}
+	if so == nil {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
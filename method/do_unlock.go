@@ -0,0 +1,41 @@
+package method
+
+import (
+	"net/http"
+	"strings"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+)
+
+//DoUnlock 解除 Lock-Token 头中的锁, 锁必须作用于请求的资源
+type DoUnlock struct {
+	resourceLocks locking.IResourceLocks
+}
+
+//BuildDoUnlock resourceLocks 为 nil 时表示未启用锁, UNLOCK 返回 405
+func BuildDoUnlock(resourceLocks locking.IResourceLocks) *DoUnlock {
+	return &DoUnlock{resourceLocks: resourceLocks}
+}
+
+func (d *DoUnlock) Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request) {
+	p := CleanPath(RelativePath(r))
+	if d.resourceLocks == nil {
+		// 未启用锁
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := LockIdFromLockTokenHeader(r)
+	if len(id) <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	lo := d.resourceLocks.LockedObjectByID(transaction, id)
+	covers := len(lo.Id) > 0 &&
+		(lo.Path == p || (lo.LockDepth != 0 && strings.HasPrefix(p, strings.TrimSuffix(lo.Path, "/")+"/")))
+	// 每个锁有自己的 lock token, 只解除 token 对应的锁, 同一资源上其他的共享锁保留
+	if !covers || !d.resourceLocks.Unlock(transaction, id, "") {
+		WriteConditionError(w, http.StatusConflict, "lock-token-matches-request-uri")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
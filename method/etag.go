@@ -0,0 +1,68 @@
+package method
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"path"
+	"sort"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//FolderHash 计算文件夹 p 的子资源列表摘要并保存到 so.ContentHash, 之后 ETag 返回的值随子资源的变化而变化
+//计算失败时保留原来的弱 ETag
+func FolderHash(transaction model.Transaction, webdavStore store.Store, p string, so *model.StoredObject) {
+	if so == nil || !so.IsFolder || len(so.ContentHash) > 0 {
+		return
+	}
+	hash, err := childrenHash(transaction, webdavStore, p)
+	if err != nil {
+		logrus.Warnf("etag of %s: %v", p, err)
+		return
+	}
+	so.ContentHash = hash
+}
+
+//childrenHash 优先使用 store.ChildrenHasher, 否则由子资源的名称、大小、修改时间和内容校验值计算
+func childrenHash(transaction model.Transaction, webdavStore store.Store, p string) (string, error) {
+	if hasher, ok := webdavStore.(store.ChildrenHasher); ok {
+		return hasher.ChildrenHash(transaction, p)
+	}
+	children := map[string]*model.StoredObject{}
+	if walker, ok := webdavStore.(store.ChildWalker); ok {
+		err := walker.WalkChildren(transaction, p, func(name string, so *model.StoredObject) error {
+			children[name] = so
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		names, err := webdavStore.ChildrenNames(transaction, p)
+		if err != nil {
+			return "", err
+		}
+		for _, name := range names {
+			so, err := webdavStore.StoredObject(transaction, path.Join(p, name))
+			if err != nil {
+				return "", err
+			}
+			children[name] = so
+		}
+	}
+
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha1.New()
+	for _, name := range names {
+		so := children[name]
+		_, _ = fmt.Fprintf(hash, "%s\x00%t\x00%d\x00%d\x00%s\n",
+			name, so.IsFolder, so.ContentLength, so.LastModified.UnixNano(), so.ContentHash)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,62 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestETag(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	for _, folder := range []string{"/a", "/b"} {
+		if err := memoryStore.CreateFolder(transaction, folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := memoryStore.SetResourceContent(transaction, "/a/1.txt", strings.NewReader("abc"), "text/plain", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	file, _ := memoryStore.StoredObject(transaction, "/a/1.txt")
+	if etag := ETag(file); etag != `"a9993e364706816aba3e25717850c26c9cd0d89d"` {
+		t.Errorf("file etag %s", etag)
+	}
+	defer func(weak bool) {
+		config.WebConf.WeakETag = weak
+	}(config.WebConf.WeakETag)
+	config.WebConf.WeakETag = true
+	if etag := ETag(file); etag != `W/"a9993e364706816aba3e25717850c26c9cd0d89d"` {
+		t.Errorf("weak file etag %s", etag)
+	}
+	config.WebConf.WeakETag = false
+
+	folderETag := func(p string) string {
+		so, _ := memoryStore.StoredObject(transaction, p)
+		FolderHash(transaction, memoryStore, p, so)
+		return ETag(so)
+	}
+	a, b := folderETag("/a"), folderETag("/b")
+	if a == b || strings.HasPrefix(a, "W/") {
+		t.Errorf("folder etags %s %s", a, b)
+	}
+	if _, err := memoryStore.SetResourceContent(transaction, "/a/1.txt", strings.NewReader("changed"), "text/plain", -1); err != nil {
+		t.Fatal(err)
+	}
+	if changed := folderETag("/a"); changed == a {
+		t.Errorf("folder etag unchanged after child changed: %s", changed)
+	}
+
+	// 文件夹的 ETag 可以用于 If-None-Match
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/b", nil)
+	r.Header.Set("If-None-Match", b)
+	BuildDoGet(memoryStore, nil, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("GET folder If-None-Match: %d", w.Code)
+	}
+}
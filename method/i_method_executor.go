@@ -0,0 +1,11 @@
+package method
+
+import (
+	"net/http"
+	"webdav-aliyundriver/model"
+)
+
+//IMethodExecutor 处理某一个 WebDAV 方法, 由 dispatch.WebDAV 按请求方法分发
+type IMethodExecutor interface {
+	Execute(transaction model.Transaction, w http.ResponseWriter, r *http.Request)
+}
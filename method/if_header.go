@@ -0,0 +1,298 @@
+package method
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"webdav-aliyundriver/config"
+)
+
+//NoLockToken 不对应任何锁的 state token, 通常以 Not <DAV:no-lock> 的形式使用
+const NoLockToken = "DAV:no-lock"
+
+//IfHeader 解析后的 If 头, 任一列表满足时整个头满足
+type IfHeader struct {
+	Lists []IfList
+}
+
+//IfList If 头中的一个条件列表, 其中全部条件满足时列表满足
+type IfList struct {
+	// 资源标记, 为空时列表作用于请求的资源
+	Resource   string
+	Conditions []IfCondition
+}
+
+//IfCondition 列表中的一个条件, Token 和 ETag 只有一个不为空
+type IfCondition struct {
+	Not   bool
+	Token string
+	ETag  string
+}
+
+//IfState 条件比较时使用的资源状态, Tokens 为资源上全部锁的 lock token
+type IfState struct {
+	ETag   string
+	Tokens []string
+}
+
+//ParseIfHeader 按 RFC 4918 第 10.4 节解析 If 头, 同一个头中不能同时出现带资源标记和不带资源标记的列表
+func ParseIfHeader(header string) (*IfHeader, error) {
+	p := &ifParser{s: header}
+	h := &IfHeader{}
+	tagged := false
+	for p.skipSpace(); !p.done(); p.skipSpace() {
+		resource := ""
+		isTagged := p.peek() == '<'
+		if isTagged {
+			ref, err := p.delimited('<', '>')
+			if err != nil {
+				return nil, err
+			}
+			if len(ref) <= 0 {
+				return nil, errors.New("if header: empty resource tag")
+			}
+			resource = ref
+			p.skipSpace()
+		}
+		if len(h.Lists) <= 0 {
+			tagged = isTagged
+		} else if tagged != isTagged {
+			return nil, errors.New("if header: tagged and untagged lists mixed")
+		}
+		if p.peek() != '(' {
+			return nil, errors.New("if header: list expected")
+		}
+		// 资源标记后面可以有多个列表, 不带标记时每个列表单独处理
+		for p.peek() == '(' {
+			conditions, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			h.Lists = append(h.Lists, IfList{Resource: resource, Conditions: conditions})
+			p.skipSpace()
+			if !isTagged {
+				break
+			}
+		}
+	}
+	if len(h.Lists) <= 0 {
+		return nil, errors.New("if header: no list")
+	}
+	return h, nil
+}
+
+//Tokens 返回头中出现的全部 state token, 即客户端提交的 lock token, 不包括 DAV:no-lock
+func (h *IfHeader) Tokens() []string {
+	var tokens []string
+	seen := map[string]bool{}
+	for _, list := range h.Lists {
+		for _, condition := range list.Conditions {
+			if len(condition.Token) <= 0 || condition.Token == NoLockToken || seen[condition.Token] {
+				continue
+			}
+			seen[condition.Token] = true
+			tokens = append(tokens, condition.Token)
+		}
+	}
+	return tokens
+}
+
+//Evaluate 计算 If 头, p 为请求的资源路径, state 返回资源的当前状态
+//带资源标记的列表作用于标记的资源, 标记不在 ContextPath 下时该列表不满足
+func (h *IfHeader) Evaluate(p string, state func(resource string) IfState) bool {
+	states := map[string]IfState{}
+	for _, list := range h.Lists {
+		resource := p
+		if len(list.Resource) > 0 {
+			var ok bool
+			if resource, ok = ifResourcePath(list.Resource); !ok {
+				continue
+			}
+		}
+		s, ok := states[resource]
+		if !ok {
+			s = state(resource)
+			states[resource] = s
+		}
+		if list.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+//String 将 If 头格式化为标准形式
+func (h *IfHeader) String() string {
+	var b strings.Builder
+	for i, list := range h.Lists {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if len(list.Resource) > 0 {
+			b.WriteString("<" + list.Resource + "> ")
+		}
+		b.WriteByte('(')
+		for j, condition := range list.Conditions {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			if condition.Not {
+				b.WriteString("Not ")
+			}
+			if len(condition.Token) > 0 {
+				b.WriteString("<" + condition.Token + ">")
+			} else {
+				b.WriteString("[" + condition.ETag + "]")
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+func (l IfList) match(s IfState) bool {
+	for _, condition := range l.Conditions {
+		if condition.match(s) == condition.Not {
+			return false
+		}
+	}
+	return true
+}
+
+//match ETag 使用弱比较, 配置了 WeakETag 时客户端也能使用 ETag 条件
+func (c IfCondition) match(s IfState) bool {
+	if len(c.ETag) > 0 {
+		return len(s.ETag) > 0 && strings.TrimPrefix(c.ETag, "W/") == strings.TrimPrefix(s.ETag, "W/")
+	}
+	for _, token := range s.Tokens {
+		if token == c.Token {
+			return true
+		}
+	}
+	return false
+}
+
+//ifResourcePath 将资源标记转换为相对于 ContextPath 的路径
+func ifResourcePath(ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	p := Normalize(u.Path)
+	contextPath := strings.TrimSuffix(config.WebConf.ContextPath, "/")
+	if len(contextPath) > 0 {
+		if p != contextPath && !strings.HasPrefix(p, contextPath+"/") {
+			return "", false
+		}
+		p = p[len(contextPath):]
+	}
+	// 没有路径的标记指向根资源
+	if len(p) <= 0 {
+		return "/", true
+	}
+	return CleanPath(p), true
+}
+
+type ifParser struct {
+	s   string
+	pos int
+}
+
+func (p *ifParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *ifParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *ifParser) skipSpace() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\r' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+//delimited 读取 open 和 close 之间的内容, 内容中不能有空白
+func (p *ifParser) delimited(open byte, close byte) (string, error) {
+	if p.peek() != open {
+		return "", errors.New("if header: " + string(open) + " expected")
+	}
+	end := strings.IndexByte(p.s[p.pos+1:], close)
+	if end < 0 {
+		return "", errors.New("if header: unterminated " + string(open))
+	}
+	value := p.s[p.pos+1 : p.pos+1+end]
+	if strings.ContainsAny(value, " \t\r\n") {
+		return "", errors.New("if header: white space in " + string(open) + string(close))
+	}
+	p.pos += end + 2
+	return value, nil
+}
+
+//list 读取 ( 和 ) 之间的一个或多个条件
+func (p *ifParser) list() ([]IfCondition, error) {
+	p.pos++
+	var conditions []IfCondition
+	for {
+		p.skipSpace()
+		if p.peek() == ')' {
+			p.pos++
+			break
+		}
+		condition := IfCondition{}
+		if strings.HasPrefix(p.s[p.pos:], "Not") {
+			condition.Not = true
+			p.pos += len("Not")
+			p.skipSpace()
+		}
+		switch p.peek() {
+		case '<':
+			token, err := p.delimited('<', '>')
+			if err != nil {
+				return nil, err
+			}
+			if len(token) <= 0 {
+				return nil, errors.New("if header: empty state token")
+			}
+			condition.Token = token
+		case '[':
+			etag, err := p.entityTag()
+			if err != nil {
+				return nil, err
+			}
+			condition.ETag = etag
+		default:
+			return nil, errors.New("if header: condition expected")
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) <= 0 {
+		return nil, errors.New("if header: empty list")
+	}
+	return conditions, nil
+}
+
+//entityTag 读取 [ 和 ] 之间的 entity-tag, 引号中可以出现 ]
+func (p *ifParser) entityTag() (string, error) {
+	start := p.pos + 1
+	i := start
+	if strings.HasPrefix(p.s[i:], "W/") {
+		i += len("W/")
+	}
+	if i >= len(p.s) || p.s[i] != '"' {
+		return "", errors.New("if header: quoted entity tag expected")
+	}
+	end := strings.IndexByte(p.s[i+1:], '"')
+	if end < 0 {
+		return "", errors.New("if header: unterminated entity tag")
+	}
+	i += end + 2
+	if i >= len(p.s) || p.s[i] != ']' {
+		return "", errors.New("if header: ] expected")
+	}
+	p.pos = i + 1
+	return p.s[start:i], nil
+}
@@ -0,0 +1,46 @@
+//go:build go1.18
+// +build go1.18
+
+package method
+
+import (
+	"reflect"
+	"testing"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+)
+
+func FuzzParseIfHeader(f *testing.F) {
+	for _, seed := range []string{
+		`(<opaquelocktoken:a>)`,
+		`(<opaquelocktoken:a> ["x"]) (Not <DAV:no-lock> [W/"y"])`,
+		`<http://example.com/a> (<opaquelocktoken:a>) (["a]b"]) </b> (Not<opaquelocktoken:b>)`,
+		`(<a>) </b> (<c>)`,
+		`(["x")`,
+		`<http://example.com> (<opaquelocktoken:a>)`,
+	} {
+		f.Add(seed)
+	}
+	resourceLocks := locking.Build()
+	f.Fuzz(func(t *testing.T, header string) {
+		h, err := ParseIfHeader(header)
+		if err != nil {
+			return
+		}
+		// 解析成功的头格式化后再次解析得到相同的结果
+		again, err := ParseIfHeader(h.String())
+		if err != nil || !reflect.DeepEqual(h, again) {
+			t.Fatalf("%q formatted as %q: %+v, %v", header, h.String(), again, err)
+		}
+		h.Tokens()
+		h.Evaluate("/", func(resource string) IfState {
+			return IfState{ETag: `"x"`, Tokens: []string{"opaquelocktoken:a"}}
+		})
+		// 任意资源标记都不能使查找锁的过程停不下来
+		for _, list := range h.Lists {
+			if resource, ok := ifResourcePath(list.Resource); ok && len(list.Resource) > 0 {
+				CoveringLocks(model.Transaction{}, resourceLocks, resource)
+			}
+		}
+	})
+}
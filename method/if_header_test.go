@@ -0,0 +1,164 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestParseIfHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *IfHeader
+	}{
+		{`(<opaquelocktoken:a>)`, &IfHeader{Lists: []IfList{
+			{Conditions: []IfCondition{{Token: "opaquelocktoken:a"}}},
+		}}},
+		{`(<opaquelocktoken:a> ["x"]) (Not <DAV:no-lock> [W/"y"])`, &IfHeader{Lists: []IfList{
+			{Conditions: []IfCondition{{Token: "opaquelocktoken:a"}, {ETag: `"x"`}}},
+			{Conditions: []IfCondition{{Not: true, Token: NoLockToken}, {ETag: `W/"y"`}}},
+		}}},
+		{`<http://example.com/a> (<opaquelocktoken:a>) (["a]b"]) </b> (Not<opaquelocktoken:b>)`, &IfHeader{Lists: []IfList{
+			{Resource: "http://example.com/a", Conditions: []IfCondition{{Token: "opaquelocktoken:a"}}},
+			{Resource: "http://example.com/a", Conditions: []IfCondition{{ETag: `"a]b"`}}},
+			{Resource: "/b", Conditions: []IfCondition{{Not: true, Token: "opaquelocktoken:b"}}},
+		}}},
+	}
+	for _, test := range tests {
+		got, err := ParseIfHeader(test.header)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %+v, %v", test.header, got, err)
+		}
+	}
+
+	for _, header := range []string{
+		``, `   `, `()`, `(<a>`, `<a>`, `(a)`, `(<>)`, `(["x")`, `([x])`, `(<a b>)`,
+		`(<a>) </b> (<c>)`, `</b> (<c>) (<a>) (<d>`, `</b> (<c>) (<a>)junk`,
+	} {
+		if h, err := ParseIfHeader(header); err == nil {
+			t.Errorf("%q parsed as %s", header, h)
+		}
+	}
+}
+
+func TestIfHeaderEvaluate(t *testing.T) {
+	states := map[string]IfState{
+		"/a.txt": {ETag: `"1"`, Tokens: []string{"opaquelocktoken:a"}},
+		"/b.txt": {ETag: `"2"`},
+	}
+	state := func(resource string) IfState {
+		return states[resource]
+	}
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`(<opaquelocktoken:a>)`, true},
+		{`(<opaquelocktoken:b>)`, false},
+		{`(<opaquelocktoken:b>) (["1"])`, true},
+		{`(<opaquelocktoken:a> ["2"])`, false},
+		{`(Not <DAV:no-lock>)`, true},
+		{`(<DAV:no-lock>)`, false},
+		{`(<opaquelocktoken:a> [W/"1"])`, true},
+		{`</b.txt> (["2"])`, true},
+		{`</b.txt> (["1"])`, false},
+		{`<http://example.com/b.txt> (Not <opaquelocktoken:a>)`, true},
+		{`<http://example.com> (<opaquelocktoken:a>)`, false},
+	}
+	for _, test := range tests {
+		h, err := ParseIfHeader(test.header)
+		if err != nil {
+			t.Fatalf("%s: %v", test.header, err)
+		}
+		if got := h.Evaluate("/a.txt", state); got != test.want {
+			t.Errorf("%s: %t, want %t", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCheckPreconditionsIfHeader(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("abc"), "text/plain", -1); err != nil {
+		t.Fatal(err)
+	}
+	so, _ := memoryStore.StoredObject(transaction, "/a.txt")
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"([" + ETag(so) + "])", http.StatusNoContent},
+		{`(["other"])`, http.StatusPreconditionFailed},
+		{`(<opaquelocktoken:a>`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/a.txt", strings.NewReader("abc"))
+		r.Header.Set("If", test.header)
+		BuildDoPut(memoryStore, nil, nil).Execute(transaction, w, r)
+		if w.Code != test.status {
+			t.Errorf("If: %s: %d, want %d", test.header, w.Code, test.status)
+		}
+	}
+
+	// 没有路径的资源标记指向根资源
+	defer func(contextPath string) {
+		config.WebConf.ContextPath = contextPath
+	}(config.WebConf.ContextPath)
+	for _, contextPath := range []string{"", "/dav"} {
+		config.WebConf.ContextPath = contextPath
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", contextPath+"/a.txt", strings.NewReader("abc"))
+		r.Header.Set("If", "<http://example.com"+contextPath+"> (<opaquelocktoken:x>)")
+		BuildDoPut(memoryStore, nil, locking.Build()).Execute(transaction, w, r)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("context path %q: %d", contextPath, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("PUT", "/a.txt", nil)
+	r.Header.Set("If", `</a.txt> (<opaquelocktoken:a> ["x"]) (Not <DAV:no-lock> <opaquelocktoken:b>)`)
+	if ids := LockIdFromIfHeader(r); !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("lock ids %v", ids)
+	}
+}
+
+func TestCheckLocks(t *testing.T) {
+	var transaction model.Transaction
+	resourceLocks := locking.Build()
+	lo, ok := resourceLocks.Lock(transaction, "/a", "alice", false, locking.DepthInfinity, 60, false)
+	if !ok {
+		t.Fatal("lock failed")
+	}
+	id := lo.Id
+
+	tests := []struct {
+		path   string
+		header string
+		want   bool
+	}{
+		{"/a/b.txt", "", false},
+		{"/a/b.txt", "(<" + LockTokenPrefix + id + ">)", true},
+		{"/a/b.txt", "(<" + LockTokenPrefix + "other>)", false},
+		{"/c.txt", "", true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("PUT", test.path, nil)
+		if len(test.header) > 0 {
+			r.Header.Set("If", test.header)
+		}
+		if got := CheckLocks(transaction, r, httptest.NewRecorder(), resourceLocks, test.path); got != test.want {
+			t.Errorf("%s If: %s: %t", test.path, test.header, got)
+		}
+	}
+	if discovery := LockDiscovery(transaction, resourceLocks, "/a/b.txt"); !strings.Contains(discovery, id) {
+		t.Errorf("lockdiscovery %s", discovery)
+	}
+}
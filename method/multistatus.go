@@ -0,0 +1,232 @@
+package method
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webdav-aliyundriver/config"
+	"webdav-aliyundriver/model"
+)
+
+const DavNamespace = "DAV:"
+
+//Propstat 状态相同的一组属性
+type Propstat struct {
+	Props  []model.Property
+	Status int
+}
+
+const (
+	// 距离上次发送超过该时间或者积累了 flushResponses 个 response 时发送给客户端
+	flushInterval  = time.Second
+	flushResponses = 256
+)
+
+//multistatus 将 207 Multi-Status 响应中的 response 逐个写入 w, 不在内存中保留已写入的 response
+type multistatus struct {
+	w         http.ResponseWriter
+	buf       *bufio.Writer
+	started   bool
+	pending   int
+	lastFlush time.Time
+}
+
+func buildMultistatus(w http.ResponseWriter) *multistatus {
+	return &multistatus{w: w}
+}
+
+//writeResponse 写入一个 response, propstats 为空时写入 status
+func (m *multistatus) writeResponse(href string, status int, propstats []Propstat) error {
+	if err := m.start(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("<D:response><D:href>")
+	escapeText(&b, href)
+	b.WriteString("</D:href>")
+	if len(propstats) <= 0 {
+		fmt.Fprintf(&b, "<D:status>%s</D:status>", statusLine(status))
+	}
+	for _, ps := range propstats {
+		b.WriteString("<D:propstat><D:prop>")
+		for _, prop := range ps.Props {
+			writeProperty(&b, prop)
+		}
+		fmt.Fprintf(&b, "</D:prop><D:status>%s</D:status></D:propstat>", statusLine(ps.Status))
+	}
+	b.WriteString("</D:response>\n")
+	if _, err := m.buf.WriteString(b.String()); err != nil {
+		return err
+	}
+	m.pending++
+	if m.pending >= flushResponses || time.Since(m.lastFlush) >= flushInterval {
+		return m.flush()
+	}
+	return nil
+}
+
+//flush 将缓冲的 response 发送给客户端
+func (m *multistatus) flush() error {
+	if err := m.buf.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := m.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	m.pending = 0
+	m.lastFlush = time.Now()
+	return nil
+}
+
+func (m *multistatus) start() error {
+	if m.started {
+		return nil
+	}
+	m.started = true
+	m.buf = bufio.NewWriter(m.w)
+	m.lastFlush = time.Now()
+	m.w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	m.w.WriteHeader(http.StatusMultiStatus)
+	_, err := m.buf.WriteString(xml.Header + "<D:multistatus xmlns:D=\"DAV:\">\n")
+	return err
+}
+
+//close 结束 multistatus, 没有写入任何 response 时也返回空的 multistatus
+func (m *multistatus) close() error {
+	if err := m.start(); err != nil {
+		return err
+	}
+	if _, err := m.buf.WriteString("</D:multistatus>\n"); err != nil {
+		return err
+	}
+	return m.flush()
+}
+
+//writeProperty DAV: 命名空间使用 D 前缀, 其他命名空间在属性元素上声明
+func writeProperty(b *strings.Builder, prop model.Property) {
+	var name string
+	switch prop.Name.Space {
+	case DavNamespace:
+		name = "D:" + prop.Name.Local
+		b.WriteString("<" + name)
+	case "":
+		name = prop.Name.Local
+		b.WriteString("<" + name + " xmlns=\"\"")
+	default:
+		name = "R:" + prop.Name.Local
+		b.WriteString("<" + name + " xmlns:R=\"")
+		escapeText(b, prop.Name.Space)
+		b.WriteString("\"")
+	}
+	if len(prop.InnerXML) <= 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">" + prop.InnerXML + "</" + name + ">")
+}
+
+//innerXML 元素的内容, 反序列化时重新生成 XML, 每个元素和属性都声明自己的命名空间,
+//放入响应中任意元素下时命名空间不变, 请求中在上级元素声明的前缀也不会丢失
+type innerXML string
+
+func (x *innerXML) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	// 当前的默认命名空间, 响应中的上级元素没有声明默认命名空间
+	defaults := []string{""}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			b.WriteString("<" + t.Name.Local)
+			if t.Name.Space != defaults[len(defaults)-1] {
+				b.WriteString(" xmlns=\"")
+				escapeText(&b, t.Name.Space)
+				b.WriteString("\"")
+			}
+			defaults = append(defaults, t.Name.Space)
+			writeAttrs(&b, t.Attr)
+			b.WriteString(">")
+		case xml.EndElement:
+			if len(defaults) <= 1 {
+				*x = innerXML(b.String())
+				return nil
+			}
+			defaults = defaults[:len(defaults)-1]
+			b.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			escapeText(&b, string(t))
+		case xml.Comment:
+			b.WriteString("<!--" + string(t) + "-->")
+		}
+	}
+}
+
+//writeAttrs 写入属性, 带命名空间的属性使用单独声明的前缀, 原来的命名空间声明不再需要
+func writeAttrs(b *strings.Builder, attrs []xml.Attr) {
+	prefixes := 0
+	for _, attr := range attrs {
+		name := attr.Name.Local
+		switch attr.Name.Space {
+		case "":
+			if name == "xmlns" {
+				continue
+			}
+		case "xmlns":
+			continue
+		case "xml":
+			name = "xml:" + name
+		default:
+			prefix := "a" + strconv.Itoa(prefixes)
+			prefixes++
+			b.WriteString(" xmlns:" + prefix + "=\"")
+			escapeText(b, attr.Name.Space)
+			b.WriteString("\"")
+			name = prefix + ":" + name
+		}
+		b.WriteString(" " + name + "=\"")
+		escapeText(b, attr.Value)
+		b.WriteString("\"")
+	}
+}
+
+//WriteConditionError 返回带有 DAV:error 的错误响应, condition 为 RFC 4918 中的前置或后置条件
+func WriteConditionError(w http.ResponseWriter, status int, condition string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s<D:error xmlns:D=\"DAV:\"><D:%s/></D:error>\n", xml.Header, condition)
+}
+
+//Href 返回 p 在响应中使用的 href, 各级名称分别转义, 文件夹以 / 结尾
+func Href(p string, isFolder bool) string {
+	segments := model.BuildPathInfo(p).Segments()
+	for i, segment := range segments {
+		segments[i] = RewriteUrl(segment)
+	}
+	href := strings.TrimSuffix(config.WebConf.ContextPath, "/") + "/" + strings.Join(segments, "/")
+	if isFolder && !strings.HasSuffix(href, "/") {
+		href += "/"
+	}
+	return href
+}
+
+func statusLine(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+func escapeText(b *strings.Builder, s string) {
+	_ = xml.EscapeText(b, []byte(s))
+}
+
+//EscapeXML 返回转义后可以放入 xml 文本中的 s
+func EscapeXML(s string) string {
+	var b strings.Builder
+	escapeText(&b, s)
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package method
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//flushRecorder 记录每次 Flush 时已经发送的内容长度
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushed = append(r.flushed, r.Body.Len())
+	r.ResponseRecorder.Flush()
+}
+
+//walkerStore 文件夹 /big 下有 count 个文件, 列出时检查已经发送的内容
+type walkerStore struct {
+	*store.MemoryStore
+	count int
+	emit  func(i int)
+}
+
+func (s *walkerStore) WalkChildren(transaction model.Transaction, folderPath string, fn func(name string, so *model.StoredObject) error) error {
+	for i := 0; i < s.count; i++ {
+		s.emit(i)
+		if err := fn(fmt.Sprintf("%d.txt", i), &model.StoredObject{ContentLength: int64(i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMultistatusFlushResponses(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if err := memoryStore.CreateFolder(transaction, "/big"); err != nil {
+		t.Fatal(err)
+	}
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	walker := &walkerStore{MemoryStore: memoryStore, count: 3*flushResponses + 10}
+	walker.emit = func(i int) {
+		// 文件夹自身和前 flushResponses-1 个文件在列出下一个文件之前发送
+		if i == flushResponses && (len(w.flushed) != 1 || !strings.Contains(w.Body.String(), Href(fmt.Sprintf("/big/%d.txt", i-2), false))) {
+			t.Errorf("%d responses, flushed %v", i, w.flushed)
+		}
+	}
+
+	r := httptest.NewRequest("PROPFIND", "/big", nil)
+	r.Header.Set("Depth", "1")
+	BuildDoPropfind(walker, nil, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusMultiStatus || strings.Count(w.Body.String(), "<D:response>") != walker.count+1 {
+		t.Errorf("propfind: %d, %d responses", w.Code, strings.Count(w.Body.String(), "<D:response>"))
+	}
+	// 每 flushResponses 个 response 一次, close 时一次
+	if len(w.flushed) != 4 || w.flushed[3] != w.Body.Len() {
+		t.Errorf("flushed %v, body %d", w.flushed, w.Body.Len())
+	}
+}
+
+func TestMultistatusFlushInterval(t *testing.T) {
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	ms := buildMultistatus(w)
+	if err := ms.writeResponse("/a", http.StatusOK, nil); err != nil || len(w.flushed) != 0 {
+		t.Fatalf("flushed %v, err %v", w.flushed, err)
+	}
+	// 距离上次发送超过 flushInterval 时不等积累足够的 response
+	ms.lastFlush = time.Now().Add(-flushInterval)
+	if err := ms.writeResponse("/b", http.StatusOK, nil); err != nil || len(w.flushed) != 1 ||
+		!strings.Contains(w.Body.String(), "<D:href>/b</D:href>") {
+		t.Errorf("flushed %v, err %v, body %s", w.flushed, err, w.Body.String())
+	}
+}
@@ -0,0 +1,160 @@
+package method
+
+import (
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+	"webdav-aliyundriver/locking"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+//CheckPreconditions 先计算 RFC 4918 的 If 头, 再按 RFC 7232 第 6 节的顺序检查 If-Match、If-Unmodified-Since、
+//If-None-Match 和 If-Modified-Since, so 为 nil 表示资源不存在, 条件不满足时写入 304、400 或 412 并返回 false
+//文件夹只在请求带有 ETag 条件时才计算子资源列表的摘要, resourceLocks 为 nil 时资源上没有锁
+func CheckPreconditions(transaction model.Transaction, webdavStore store.Store, resourceLocks locking.IResourceLocks,
+	w http.ResponseWriter, r *http.Request, p string, so *model.StoredObject) bool {
+	if header := r.Header.Get("If"); len(header) > 0 {
+		ifHeader, err := ParseIfHeader(header)
+		if err != nil {
+			logrus.Debugf("%s %s: %v", r.Method, p, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return false
+		}
+		state := func(resource string) IfState {
+			return ifState(transaction, webdavStore, resourceLocks, resource, p, so)
+		}
+		if !ifHeader.Evaluate(p, state) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if len(r.Header.Get("If-Match")) > 0 || len(r.Header.Get("If-None-Match")) > 0 {
+		FolderHash(transaction, webdavStore, p, so)
+	}
+	status := preconditionStatus(r, so)
+	if status == 0 {
+		return true
+	}
+	if status == http.StatusNotModified {
+		w.Header().Set("ETag", ETag(so))
+		if !so.LastModified.IsZero() {
+			w.Header().Set("Last-Modified", so.LastModified.UTC().Format(http.TimeFormat))
+		}
+	}
+	w.WriteHeader(status)
+	return false
+}
+
+//ifState 返回 If 头中资源的 ETag 和作用于资源的锁的 lock token
+func ifState(transaction model.Transaction, webdavStore store.Store, resourceLocks locking.IResourceLocks,
+	resource string, p string, so *model.StoredObject) IfState {
+	state := IfState{}
+	if resource != p {
+		so, _ = webdavStore.StoredObject(transaction, resource)
+	}
+	if so != nil {
+		FolderHash(transaction, webdavStore, resource, so)
+		state.ETag = ETag(so)
+	}
+	if resourceLocks == nil {
+		return state
+	}
+	for _, lo := range CoveringLocks(transaction, resourceLocks, resource) {
+		state.Tokens = append(state.Tokens, LockTokenPrefix+lo.Id)
+	}
+	return state
+}
+
+//preconditionStatus 条件满足时返回 0
+func preconditionStatus(r *http.Request, so *model.StoredObject) int {
+	etag := ""
+	if so != nil {
+		etag = ETag(so)
+	}
+	// 没有修改时间的资源忽略日期条件
+	dated := so != nil && !so.LastModified.IsZero()
+	if ifMatch := r.Header.Get("If-Match"); len(ifMatch) > 0 {
+		// If-Match 使用强比较, 弱 ETag 永远不匹配
+		if !matchETag(ifMatch, etag, so != nil, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := headerTime(r, "If-Unmodified-Since"); ok && dated && modifiedAfter(so, since) {
+		return http.StatusPreconditionFailed
+	}
+
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if ifNoneMatch := r.Header.Get("If-None-Match"); len(ifNoneMatch) > 0 {
+		if matchETag(ifNoneMatch, etag, so != nil, false) {
+			if readOnly {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := headerTime(r, "If-Modified-Since"); ok && readOnly && dated && !modifiedAfter(so, since) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+//matchETag 判断 etag 是否在 header 的列表中, "*" 匹配任意存在的资源
+func matchETag(header string, etag string, exists bool, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return exists
+	}
+	if len(etag) <= 0 || (strong && strings.HasPrefix(etag, "W/")) {
+		return false
+	}
+	for _, candidate := range splitETags(header) {
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+//splitETags 拆分逗号分隔的 entity-tag 列表, 引号中的逗号不作为分隔符
+func splitETags(header string) []string {
+	var etags []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(header); i++ {
+		switch header[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				etags = appendETag(etags, header[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendETag(etags, header[start:])
+}
+
+func appendETag(etags []string, etag string) []string {
+	etag = strings.TrimSpace(etag)
+	if len(etag) <= 0 {
+		return etags
+	}
+	return append(etags, etag)
+}
+
+//headerTime 解析日期头, 不存在或者格式错误时忽略
+func headerTime(r *http.Request, name string) (time.Time, bool) {
+	value := r.Header.Get(name)
+	if len(value) <= 0 {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	return t, err == nil
+}
+
+//modifiedAfter HTTP 日期只精确到秒, 比较前去掉修改时间中不足一秒的部分
+func modifiedAfter(so *model.StoredObject, since time.Time) bool {
+	return so.LastModified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,92 @@
+package method
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+func TestPreconditionStatus(t *testing.T) {
+	modified := time.Date(2021, 6, 1, 12, 0, 0, 500, time.UTC)
+	so := &model.StoredObject{ContentLength: 3, LastModified: modified}
+	etag := ETag(so)
+	strong := &model.StoredObject{ContentLength: 3, LastModified: modified, ContentHash: "a9993e36"}
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	at := modified.Format(http.TimeFormat)
+
+	tests := []struct {
+		method string
+		header string
+		value  string
+		so     *model.StoredObject
+		status int
+	}{
+		{"GET", "If-None-Match", etag, so, http.StatusNotModified},
+		{"GET", "If-None-Match", `"other", ` + etag, so, http.StatusNotModified},
+		{"GET", "If-None-Match", `"other"`, so, 0},
+		{"PUT", "If-None-Match", "*", so, http.StatusPreconditionFailed},
+		{"PUT", "If-None-Match", "*", nil, 0},
+		{"DELETE", "If-None-Match", etag, so, http.StatusPreconditionFailed},
+		{"PUT", "If-Match", "*", nil, http.StatusPreconditionFailed},
+		{"PUT", "If-Match", "*", so, 0},
+		// 弱 ETag 不能用于 If-Match
+		{"PUT", "If-Match", etag, so, http.StatusPreconditionFailed},
+		{"PUT", "If-Match", `"a9993e36"`, strong, 0},
+		{"PUT", "If-Match", `W/"a9993e36"`, strong, http.StatusPreconditionFailed},
+		{"GET", "If-None-Match", `W/"a9993e36"`, strong, http.StatusNotModified},
+		{"GET", "If-Modified-Since", at, so, http.StatusNotModified},
+		{"GET", "If-Modified-Since", before, so, 0},
+		{"PUT", "If-Modified-Since", at, so, 0},
+		{"GET", "If-Modified-Since", "not a date", so, 0},
+		{"PUT", "If-Unmodified-Since", before, so, http.StatusPreconditionFailed},
+		{"MOVE", "If-Unmodified-Since", at, so, 0},
+		{"GET", "If-Unmodified-Since", before, &model.StoredObject{}, 0},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/a.txt", nil)
+		r.Header.Set(test.header, test.value)
+		if status := preconditionStatus(r, test.so); status != test.status {
+			t.Errorf("%s %s: %s: %d, want %d", test.method, test.header, test.value, status, test.status)
+		}
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	var transaction model.Transaction
+	memoryStore := store.BuildMemoryStore()
+	if _, err := memoryStore.SetResourceContent(transaction, "/a.txt", strings.NewReader("abc"), "text/plain", -1); err != nil {
+		t.Fatal(err)
+	}
+	so, _ := memoryStore.StoredObject(transaction, "/a.txt")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("HEAD", "/a.txt", nil)
+	r.Header.Set("If-None-Match", ETag(so))
+	BuildDoHead(memoryStore, nil, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != ETag(so) || w.Body.Len() > 0 {
+		t.Errorf("HEAD If-None-Match: %d ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/a.txt", strings.NewReader("changed"))
+	r.Header.Set("If-None-Match", "*")
+	BuildDoPut(memoryStore, nil, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT If-None-Match *: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/a.txt", nil)
+	r.Header.Set("If-Match", `"other"`)
+	BuildDoDelete(memoryStore, nil, nil).Execute(transaction, w, r)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE If-Match: %d", w.Code)
+	}
+	if _, err := memoryStore.StoredObject(transaction, "/a.txt"); err != nil {
+		t.Errorf("file removed despite failed precondition: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package method
+
+import (
+	"encoding/xml"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"webdav-aliyundriver/model"
+	"webdav-aliyundriver/store"
+)
+
+const MicrosoftNamespace = "urn:schemas-microsoft-com:"
+
+//Windows 复制文件时通过 PROPPATCH 设置的原文件时间, 格式为 RFC 1123
+var (
+	Win32LastModifiedTime = xml.Name{Space: MicrosoftNamespace, Local: "Win32LastModifiedTime"}
+	Win32CreationTime     = xml.Name{Space: MicrosoftNamespace, Local: "Win32CreationTime"}
+)
+
+func isWin32Time(name xml.Name) bool {
+	return name == Win32LastModifiedTime || name == Win32CreationTime
+}
+
+//OverlayTimes 后端不能保存 Win32 时间时, 用服务器记录的时间替换 so 中的修改时间和创建时间
+func OverlayTimes(props store.PropertyStore, p string, so *model.StoredObject) {
+	if props == nil {
+		return
+	}
+	list, err := props.Properties(p)
+	if err != nil {
+		logrus.Errorf("properties of %s: %v", p, err)
+		return
+	}
+	for _, prop := range list {
+		if !isWin32Time(prop.Name) {
+			continue
+		}
+		t, err := http.ParseTime(strings.TrimSpace(prop.InnerXML))
+		if err != nil {
+			continue
+		}
+		if prop.Name == Win32LastModifiedTime {
+			so.LastModified = t
+		} else {
+			so.CreationDate = t
+		}
+	}
+}
@@ -1,5 +1,6 @@
 package model
 
+//MoveRequestId 批量移动中的一个子请求, Id 为子请求在批量请求中的 id, CcpFileId 为被移动的文件
 type MoveRequestId struct {
 	CcpFileId string
 	Id        string
@@ -1,8 +1,8 @@
 package model
 
 type Page struct {
-	marker         string
-	Limit          int32
-	OrderBy        string
-	OrderDirection string
+	Marker         string `json:"marker,omitempty"`
+	Limit          int32  `json:"limit,omitempty"`
+	OrderBy        string `json:"order_by,omitempty"`
+	OrderDirection string `json:"order_direction,omitempty"`
 }
@@ -1,7 +1,33 @@
 package model
 
+import (
+	"path"
+	"strings"
+)
+
 type PathInfo struct {
 	Path       string
 	ParentPath string
 	Name       string
 }
+
+//BuildPathInfo 将 p 规范化后拆分为父路径和名称, 根路径的父路径和名称都为空
+func BuildPathInfo(p string) PathInfo {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return PathInfo{Path: p}
+	}
+	return PathInfo{
+		Path:       p,
+		ParentPath: path.Dir(p),
+		Name:       path.Base(p),
+	}
+}
+
+//Segments 返回路径中的各级名称, 根路径返回空
+func (p PathInfo) Segments() []string {
+	if p.Path == "/" || len(p.Path) <= 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(p.Path, "/"), "/")
+}
@@ -0,0 +1,9 @@
+package model
+
+import "encoding/xml"
+
+//Property WebDAV 属性, InnerXML 为已经转义的属性内容
+type Property struct {
+	Name     xml.Name `json:"name"`
+	InnerXML string   `json:"inner_xml"`
+}
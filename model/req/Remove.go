@@ -1,6 +1,6 @@
 package req
 
 type Remove struct {
-	DriveId string
-	FileId  string
+	DriveId string `json:"drive_id"`
+	FileId  string `json:"file_id"`
 }
@@ -2,8 +2,8 @@ package req
 
 type Rename struct {
 	// refuse
-	CheckNameMode string
-	DriveId       string
-	Name          string
-	FileId        string
+	CheckNameMode string `json:"check_name_mode"`
+	DriveId       string `json:"drive_id"`
+	Name          string `json:"name"`
+	FileId        string `json:"file_id"`
 }
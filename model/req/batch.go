@@ -0,0 +1,17 @@
+package req
+
+//Batch 批量请求, 每个子请求对应一个单独的接口调用
+type Batch struct {
+	Requests []BatchRequest `json:"requests"`
+	// file
+	Resource string `json:"resource"`
+}
+
+type BatchRequest struct {
+	Body    interface{}       `json:"body"`
+	Headers map[string]string `json:"headers"`
+	Id      string            `json:"id"`
+	Method  string            `json:"method"`
+	// 不带版本前缀的接口地址, 如 /file/move
+	Url string `json:"url"`
+}
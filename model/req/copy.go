@@ -0,0 +1,10 @@
+package req
+
+type Copy struct {
+	DriveId        string `json:"drive_id"`
+	FileId         string `json:"file_id"`
+	ToDriveId      string `json:"to_drive_id"`
+	ToParentFileId string `json:"to_parent_file_id"`
+	// 目标文件夹中有同名文件时自动改名
+	AutoRename bool `json:"auto_rename"`
+}
@@ -2,11 +2,11 @@ package req
 
 type CreateFile struct {
 	// 默认 "refuse"
-	CheckNameMode string
-	DriveId       string
-	Name          string
-	ParentFileId  string
-	Type          string
+	CheckNameMode string `json:"check_name_mode"`
+	DriveId       string `json:"drive_id"`
+	Name          string `json:"name"`
+	ParentFileId  string `json:"parent_file_id"`
+	Type          string `json:"type"`
 }
 
 func init() {
@@ -1,8 +1,8 @@
 package req
 
 type Download struct {
-	DriveId string
-	FileId  string
+	DriveId string `json:"drive_id"`
+	FileId  string `json:"file_id"`
 	// 默认 14400
-	expireSec int32
+	ExpireSec int32 `json:"expire_sec"`
 }
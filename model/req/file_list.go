@@ -1,18 +1,21 @@
 package req
 
+import "webdav-aliyundriver/model"
+
 type FileList struct {
+	model.Page
 	// ;
-	DriveId string
+	DriveId string `json:"drive_id"`
 	//  = false;
-	all bool
+	All bool `json:"all"`
 	//  = "*";
-	fields string
+	Fields string `json:"fields"`
 	//  = "image/resize,w_400/format,jpeg";
-	ImageThumbnailProcess string
+	ImageThumbnailProcess string `json:"image_thumbnail_process,omitempty"`
 	//  = "image/resize,w_1920/format,jpeg";
-	ImageUrlProcess string
+	ImageUrlProcess string `json:"image_url_process,omitempty"`
 	// ;
-	ParentFileId string
+	ParentFileId string `json:"parent_file_id"`
 	//  = "video/snapshot,t_0,f_jpg,ar_auto,w_300";
-	VideoThumbnailProcess string
+	VideoThumbnailProcess string `json:"video_thumbnail_process,omitempty"`
 }
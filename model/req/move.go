@@ -1,7 +1,7 @@
 package req
 
 type Move struct {
-	DriveId string
-	FileId string
-	ToParentFileId string
+	DriveId        string `json:"drive_id"`
+	FileId         string `json:"file_id"`
+	ToParentFileId string `json:"to_parent_file_id"`
 }
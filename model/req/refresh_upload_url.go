@@ -1,8 +1,8 @@
 package req
 
 type RefreshUploadUrl struct {
-	DriveId      string
-	PartInfoList []PartInfo
-	FileId       string
-	UploadId     string
+	DriveId      string     `json:"drive_id"`
+	PartInfoList []PartInfo `json:"part_info_list"`
+	FileId       string     `json:"file_id"`
+	UploadId     string     `json:"upload_id"`
 }
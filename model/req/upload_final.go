@@ -1,7 +1,7 @@
 package req
 
 type UploadFinal struct {
-	DriveId  string
-	FileId   string
-	UploadId string
+	DriveId  string `json:"drive_id"`
+	FileId   string `json:"file_id"`
+	UploadId string `json:"upload_id"`
 }
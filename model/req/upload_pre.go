@@ -3,29 +3,29 @@ package req
 type UploadPre struct {
 
 	//  = "refuse";
-	CheckNameMode string
+	CheckNameMode string `json:"check_name_mode"`
 	// ;
-	ContentHash string
+	ContentHash string `json:"content_hash,omitempty"`
 	//  = "none";
-	ContentHashName string
+	ContentHashName string `json:"content_hash_name"`
 	// ;
-	DriveId string
+	DriveId string `json:"drive_id"`
 	// ;
-	name string
+	Name string `json:"name"`
 	// ;
-	ParentFileId string
+	ParentFileId string `json:"parent_file_id"`
 	// ;
-	ProofCode string
+	ProofCode string `json:"proof_code,omitempty"`
 	//  = "v1";
-	ProofVersion string
+	ProofVersion string `json:"proof_version"`
 	// ;
-	size         int64
-	PartInfoList []PartInfo
+	Size         int64      `json:"size"`
+	PartInfoList []PartInfo `json:"part_info_list,omitempty"`
 	//  = "file";
-	Type string
+	Type string `json:"type"`
 }
 
 type PartInfo struct {
-	PartNumber int32
-	UploadUrl  string
+	PartNumber int32  `json:"part_number"`
+	UploadUrl  string `json:"upload_url,omitempty"`
 }
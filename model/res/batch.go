@@ -0,0 +1,14 @@
+package res
+
+import "encoding/json"
+
+//Batch 批量请求的结果, 子请求的结果通过 Id 对应
+type Batch struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+type BatchResponse struct {
+	Id     string          `json:"id"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
@@ -0,0 +1,8 @@
+package res
+
+type Copy struct {
+	DriveId string `json:"drive_id"`
+	FileId  string `json:"file_id"`
+	// 复制文件夹时在后台完成, 返回任务 id
+	AsyncTaskId string `json:"async_task_id"`
+}
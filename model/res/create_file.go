@@ -1,8 +1,10 @@
 package res
 
 type CreateFile struct {
-	CcpFileId string
-	NodeId    string
-	Name      string
-	Kind      string
+	CcpFileId string `json:"file_id"`
+	NodeId    string `json:"node_id"`
+	Name      string `json:"file_name"`
+	Kind      string `json:"type"`
+	// check_name_mode 为 refuse 且同名文件已存在时为 true, 此时返回的是已有的文件
+	Exist bool `json:"exist"`
 }
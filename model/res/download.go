@@ -0,0 +1,10 @@
+package res
+
+import "time"
+
+type Download struct {
+	Url        string    `json:"url"`
+	Expiration time.Time `json:"expiration"`
+	Method     string    `json:"method"`
+	Size       int64     `json:"size"`
+}
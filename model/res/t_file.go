@@ -3,22 +3,24 @@ package res
 import "time"
 
 type TFile struct {
-
-
-	  CreatedAt time.Time
-	  DomainId string
-	  DriveId string
-	  EncryptMode string
-	  FileId string
-	  Hidden bool
-	  Name string
-	  FileName string
-	  ParentFileId string
-	  Starred string
-	  Status string
-	  Type string
-	  UpdatedAt time.Time
-	  Url string
-	  Size int64
-	  DownloadUrl string
+	CreatedAt    time.Time `json:"created_at"`
+	DomainId     string    `json:"domain_id"`
+	DriveId      string    `json:"drive_id"`
+	EncryptMode  string    `json:"encrypt_mode"`
+	FileId       string    `json:"file_id"`
+	Hidden       bool      `json:"hidden"`
+	Name         string    `json:"name"`
+	FileName     string    `json:"file_name"`
+	ParentFileId string    `json:"parent_file_id"`
+	Starred      bool      `json:"starred"`
+	Status       string    `json:"status"`
+	Type         string    `json:"type"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Url          string    `json:"url"`
+	Size         int64     `json:"size"`
+	DownloadUrl  string    `json:"download_url"`
+	MimeType     string    `json:"mime_type"`
+	// 文件内容的 sha1, 大写十六进制
+	ContentHash     string `json:"content_hash"`
+	ContentHashName string `json:"content_hash_name"`
 }
@@ -1,6 +1,6 @@
 package res
 
 type TFileList struct {
-	Items      []interface{}
-	NextMarker string
+	Items      []TFile `json:"items"`
+	NextMarker string  `json:"next_marker"`
 }
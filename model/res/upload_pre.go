@@ -3,11 +3,11 @@ package res
 import "webdav-aliyundriver/model/req"
 
 type UploadPre struct {
-	FileId       string
-	FileName     string
-	Location     string
-	RapidUpload  bool
-	Type         string
-	UploadId     string
-	PartInfoList []req.PartInfo
+	FileId       string         `json:"file_id"`
+	FileName     string         `json:"file_name"`
+	Location     string         `json:"location"`
+	RapidUpload  bool           `json:"rapid_upload"`
+	Type         string         `json:"type"`
+	UploadId     string         `json:"upload_id"`
+	PartInfoList []req.PartInfo `json:"part_info_list"`
 }
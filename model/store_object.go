@@ -9,4 +9,6 @@ type StoredObject struct {
 	ContentLength  int64
 	MineType       string
 	IsNullResource bool
+	// 存储提供的内容校验值, 例如 sha1, 文件夹为子资源列表的摘要, 为空时只能生成弱 ETag
+	ContentHash string
 }
@@ -6,3 +6,19 @@ type Transaction struct {
 	req *http.Request
 	res http.ResponseWriter
 }
+
+//BuildTransaction 为一次 WebDAV 请求创建事务
+func BuildTransaction(res http.ResponseWriter, req *http.Request) Transaction {
+	return Transaction{
+		req: req,
+		res: res,
+	}
+}
+
+func (t Transaction) Request() *http.Request {
+	return t.req
+}
+
+func (t Transaction) Response() http.ResponseWriter {
+	return t.res
+}
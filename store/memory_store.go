@@ -0,0 +1,255 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"webdav-aliyundriver/model"
+)
+
+//MemoryStore 基于内存的 Store 实现, 用于测试和不需要持久化的场景
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	model.StoredObject
+	content []byte
+}
+
+func BuildMemoryStore() *MemoryStore {
+	now := time.Now()
+	return &MemoryStore{
+		objects: map[string]*memoryObject{
+			"/": {StoredObject: model.StoredObject{IsFolder: true, LastModified: now, CreationDate: now}},
+		},
+	}
+}
+
+func (s *MemoryStore) StoredObject(transaction model.Transaction, p string) (*model.StoredObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[cleanPath(p)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	so := object.StoredObject
+	return &so, nil
+}
+
+func (s *MemoryStore) ChildrenNames(transaction model.Transaction, folderPath string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	folderPath = cleanPath(folderPath)
+	if object, ok := s.objects[folderPath]; !ok || !object.IsFolder {
+		return nil, ErrNotFound
+	}
+	var names []string
+	for p := range s.objects {
+		if p != "/" && path.Dir(p) == folderPath {
+			names = append(names, path.Base(p))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *MemoryStore) ResourceContent(transaction model.Transaction, p string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[cleanPath(p)]
+	if !ok || object.IsFolder {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.content)), nil
+}
+
+func (s *MemoryStore) ResourceRange(transaction model.Transaction, p string, offset int64, length int64) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	object, ok := s.objects[cleanPath(p)]
+	if !ok || object.IsFolder {
+		return nil, ErrNotFound
+	}
+	size := int64(len(object.content))
+	if offset > size {
+		offset = size
+	}
+	end := size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.content[offset:end])), nil
+}
+
+func (s *MemoryStore) SetResourceContent(transaction model.Transaction, p string, content io.Reader,
+	contentType string, length int64) (int64, error) {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = cleanPath(p)
+	if err := s.checkParent(p); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	object, ok := s.objects[p]
+	if ok && object.IsFolder {
+		return 0, ErrExists
+	}
+	if !ok {
+		object = &memoryObject{StoredObject: model.StoredObject{CreationDate: now}}
+		s.objects[p] = object
+	}
+	hash := sha1.Sum(data)
+	object.content = data
+	object.ContentLength = int64(len(data))
+	object.ContentHash = hex.EncodeToString(hash[:])
+	object.MineType = contentType
+	object.LastModified = now
+	return object.ContentLength, nil
+}
+
+func (s *MemoryStore) CreateFolder(transaction model.Transaction, folderPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	folderPath = cleanPath(folderPath)
+	if _, ok := s.objects[folderPath]; ok {
+		return ErrExists
+	}
+	if err := s.checkParent(folderPath); err != nil {
+		return err
+	}
+	now := time.Now()
+	s.objects[folderPath] = &memoryObject{
+		StoredObject: model.StoredObject{IsFolder: true, LastModified: now, CreationDate: now},
+	}
+	return nil
+}
+
+func (s *MemoryStore) RemoveObject(transaction model.Transaction, p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = cleanPath(p)
+	if _, ok := s.objects[p]; !ok || p == "/" {
+		return ErrNotFound
+	}
+	for _, member := range s.tree(p) {
+		delete(s.objects, member)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Move(transaction model.Transaction, p string, toParentPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = cleanPath(p)
+	return s.moveTree(p, path.Join(cleanPath(toParentPath), path.Base(p)))
+}
+
+func (s *MemoryStore) Rename(transaction model.Transaction, p string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = cleanPath(p)
+	return s.moveTree(p, path.Join(path.Dir(p), name))
+}
+
+func (s *MemoryStore) Copy(transaction model.Transaction, p string, destinationPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = cleanPath(p)
+	destinationPath = cleanPath(destinationPath)
+	if _, ok := s.objects[p]; !ok || p == "/" {
+		return ErrNotFound
+	}
+	if _, ok := s.objects[destinationPath]; ok {
+		return ErrExists
+	}
+	if err := s.checkParent(destinationPath); err != nil {
+		return err
+	}
+	if strings.HasPrefix(destinationPath+"/", p+"/") {
+		return ErrConflict
+	}
+	now := time.Now()
+	for _, member := range s.tree(p) {
+		object := *s.objects[member]
+		object.CreationDate = now
+		object.LastModified = now
+		s.objects[destinationPath+member[len(p):]] = &object
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetTimes(transaction model.Transaction, p string, lastModified time.Time, creationDate time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[cleanPath(p)]
+	if !ok {
+		return ErrNotFound
+	}
+	if !lastModified.IsZero() {
+		object.LastModified = lastModified
+	}
+	if !creationDate.IsZero() {
+		object.CreationDate = creationDate
+	}
+	return nil
+}
+
+func (s *MemoryStore) moveTree(p string, destinationPath string) error {
+	if _, ok := s.objects[p]; !ok || p == "/" {
+		return ErrNotFound
+	}
+	if p == destinationPath {
+		return nil
+	}
+	if _, ok := s.objects[destinationPath]; ok {
+		return ErrExists
+	}
+	if err := s.checkParent(destinationPath); err != nil {
+		return err
+	}
+	if strings.HasPrefix(destinationPath+"/", p+"/") {
+		return ErrConflict
+	}
+	for _, member := range s.tree(p) {
+		s.objects[destinationPath+member[len(p):]] = s.objects[member]
+		delete(s.objects, member)
+	}
+	return nil
+}
+
+//tree 返回 p 及其所有子资源的路径
+func (s *MemoryStore) tree(p string) []string {
+	members := []string{p}
+	for member := range s.objects {
+		if strings.HasPrefix(member, p+"/") {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+func (s *MemoryStore) checkParent(p string) error {
+	parent, ok := s.objects[path.Dir(p)]
+	if !ok || !parent.IsFolder {
+		return ErrConflict
+	}
+	return nil
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"webdav-aliyundriver/model"
+)
+
+func TestMemoryStore(t *testing.T) {
+	var transaction model.Transaction
+	s := BuildMemoryStore()
+
+	if err := s.CreateFolder(transaction, "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateFolder(transaction, "/x/y"); !errors.Is(err, ErrConflict) {
+		t.Errorf("create folder without parent: %v", err)
+	}
+	if _, err := s.SetResourceContent(transaction, "/a/f.txt", strings.NewReader("hello"), "text/plain", 5); err != nil {
+		t.Fatal(err)
+	}
+	so, err := s.StoredObject(transaction, "/a/f.txt")
+	if err != nil || so.ContentLength != 5 || so.IsFolder {
+		t.Fatalf("stored object: %+v, %v", so, err)
+	}
+
+	if err := s.Copy(transaction, "/a", "/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rename(transaction, "/b/f.txt", "g.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Move(transaction, "/b/g.txt", "/a"); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := s.ChildrenNames(transaction, "/a")
+	if !reflect.DeepEqual(names, []string{"f.txt", "g.txt"}) {
+		t.Errorf("children of /a: %v", names)
+	}
+	if err := s.Move(transaction, "/a", "/a"); !errors.Is(err, ErrConflict) {
+		t.Errorf("move into itself: %v", err)
+	}
+
+	content, err := s.ResourceContent(transaction, "/a/g.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(content)
+	if string(data) != "hello" {
+		t.Errorf("content: %q", data)
+	}
+
+	if err := s.RemoveObject(transaction, "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StoredObject(transaction, "/a/f.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removed child still exists: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"webdav-aliyundriver/model"
+)
+
+//PropertyStore 保存客户端通过 PROPPATCH 设置的 dead property, 以资源路径为键
+type PropertyStore interface {
+
+	//Properties 返回 path 的全部 dead property
+	Properties(path string) ([]model.Property, error)
+
+	//Patch 按顺序应用 patches, 全部成功或者全部不生效
+	Patch(path string, patches []PropertyPatch) error
+
+	//Move 将 path 及其子路径的属性转移到 destinationPath 下
+	Move(path string, destinationPath string) error
+
+	//Copy 将 path 的属性复制到 destinationPath, recursive 为 true 时包括子路径
+	Copy(path string, destinationPath string, recursive bool) error
+
+	//Remove 删除 path 及其子路径的属性
+	Remove(path string) error
+}
+
+//PropertyPatch PROPPATCH 中的一个 set 或 remove 操作
+type PropertyPatch struct {
+	Remove bool
+	Props  []model.Property
+}
+
+//MemoryPropertyStore 基于内存的 PropertyStore 实现
+type MemoryPropertyStore struct {
+	mu    sync.RWMutex
+	props map[string][]model.Property
+}
+
+func BuildMemoryPropertyStore() *MemoryPropertyStore {
+	return &MemoryPropertyStore{props: map[string][]model.Property{}}
+}
+
+func (s *MemoryPropertyStore) Properties(p string) ([]model.Property, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]model.Property(nil), s.props[cleanPath(p)]...), nil
+}
+
+func (s *MemoryPropertyStore) Patch(p string, patches []PropertyPatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patch(cleanPath(p), patches)
+	return nil
+}
+
+func (s *MemoryPropertyStore) Move(p string, destinationPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.move(cleanPath(p), cleanPath(destinationPath))
+	return nil
+}
+
+func (s *MemoryPropertyStore) Copy(p string, destinationPath string, recursive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.copy(cleanPath(p), cleanPath(destinationPath), recursive)
+	return nil
+}
+
+func (s *MemoryPropertyStore) Remove(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(cleanPath(p))
+	return nil
+}
+
+//patch 在副本上应用 patches 之后整体替换, 调用方持有写锁
+func (s *MemoryPropertyStore) patch(p string, patches []PropertyPatch) {
+	props := append([]model.Property(nil), s.props[p]...)
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			props = removeProperty(props, prop)
+			if !patch.Remove {
+				props = append(props, prop)
+			}
+		}
+	}
+	if len(props) <= 0 {
+		delete(s.props, p)
+		return
+	}
+	s.props[p] = props
+}
+
+func (s *MemoryPropertyStore) move(p string, destinationPath string) {
+	moved := map[string][]model.Property{}
+	for member, props := range s.props {
+		if member == p || strings.HasPrefix(member, p+"/") {
+			moved[destinationPath+member[len(p):]] = props
+			delete(s.props, member)
+		}
+	}
+	s.remove(destinationPath)
+	for member, props := range moved {
+		s.props[member] = props
+	}
+}
+
+func (s *MemoryPropertyStore) copy(p string, destinationPath string, recursive bool) {
+	copied := map[string][]model.Property{}
+	for member, props := range s.props {
+		if member == p || (recursive && strings.HasPrefix(member, p+"/")) {
+			copied[destinationPath+member[len(p):]] = append([]model.Property(nil), props...)
+		}
+	}
+	for member, props := range copied {
+		s.props[member] = props
+	}
+}
+
+func (s *MemoryPropertyStore) remove(p string) {
+	for member := range s.props {
+		if member == p || strings.HasPrefix(member, p+"/") {
+			delete(s.props, member)
+		}
+	}
+}
+
+func removeProperty(props []model.Property, prop model.Property) []model.Property {
+	for i := range props {
+		if props[i].Name == prop.Name {
+			return append(props[:i], props[i+1:]...)
+		}
+	}
+	return props
+}
+
+//FilePropertyStore 将属性保存在 json 文件中的 PropertyStore 实现, 每次修改后整体写入
+type FilePropertyStore struct {
+	MemoryPropertyStore
+	file string
+}
+
+//BuildFilePropertyStore 读取 file 中保存的属性, 文件不存在时从空开始
+func BuildFilePropertyStore(file string) (*FilePropertyStore, error) {
+	s := &FilePropertyStore{
+		MemoryPropertyStore: MemoryPropertyStore{props: map[string][]model.Property{}},
+		file:                file,
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.props); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FilePropertyStore) Patch(p string, patches []PropertyPatch) error {
+	return s.update(func(next *MemoryPropertyStore) {
+		next.patch(cleanPath(p), patches)
+	})
+}
+
+func (s *FilePropertyStore) Move(p string, destinationPath string) error {
+	return s.update(func(next *MemoryPropertyStore) {
+		next.move(cleanPath(p), cleanPath(destinationPath))
+	})
+}
+
+func (s *FilePropertyStore) Copy(p string, destinationPath string, recursive bool) error {
+	return s.update(func(next *MemoryPropertyStore) {
+		next.copy(cleanPath(p), cleanPath(destinationPath), recursive)
+	})
+}
+
+func (s *FilePropertyStore) Remove(p string) error {
+	return s.update(func(next *MemoryPropertyStore) {
+		next.remove(cleanPath(p))
+	})
+}
+
+//update 在副本上修改并写入文件, 写入失败时保留原来的属性
+func (s *FilePropertyStore) update(fn func(next *MemoryPropertyStore)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := &MemoryPropertyStore{props: make(map[string][]model.Property, len(s.props))}
+	for p, props := range s.props {
+		next.props[p] = props
+	}
+	fn(next)
+	if err := s.save(next.props); err != nil {
+		return err
+	}
+	s.props = next.props
+	return nil
+}
+
+//save 先写入临时文件再重命名, 中途退出不会留下不完整的文件
+func (s *FilePropertyStore) save(props map[string][]model.Property) error {
+	data, err := json.Marshal(props)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"testing"
+	"webdav-aliyundriver/model"
+)
+
+func TestFilePropertyStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "props.json")
+	s, err := BuildFilePropertyStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	color := model.Property{Name: xml.Name{Space: "urn:example", Local: "color"}, InnerXML: "red"}
+	if err := s.Patch("/a/b.txt", []PropertyPatch{{Props: []model.Property{color}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Move("/a", "/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新读取文件
+	s, err = BuildFilePropertyStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props, _ := s.Properties("/a/b.txt"); len(props) != 0 {
+		t.Errorf("moved properties left behind: %v", props)
+	}
+	if props, _ := s.Properties("/c/b.txt"); len(props) != 1 || props[0] != color {
+		t.Errorf("moved properties: %v", props)
+	}
+	if err := s.Remove("/c"); err != nil {
+		t.Fatal(err)
+	}
+	if props, _ := s.Properties("/c/b.txt"); len(props) != 0 {
+		t.Errorf("removed properties: %v", props)
+	}
+}
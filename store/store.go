@@ -0,0 +1,93 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"time"
+	"webdav-aliyundriver/model"
+)
+
+var (
+	ErrNotFound = errors.New("resource not found")
+	ErrExists   = errors.New("resource already exists")
+	// 父文件夹不存在或者不是文件夹
+	ErrConflict = errors.New("parent resource missing or not a folder")
+	// 后端不支持该操作, 调用方可以改用其他方式完成
+	ErrNotSupported = errors.New("operation not supported by store")
+)
+
+//Store WebDAV 方法使用的存储后端, 所有 path 都是以 / 开头、已去除 ContextPath 的路径
+type Store interface {
+
+	//StoredObject 获取 path 的元数据, 不存在时返回 ErrNotFound
+	StoredObject(transaction model.Transaction, path string) (*model.StoredObject, error)
+
+	//ChildrenNames 列出文件夹 folderPath 下的子资源名称
+	ChildrenNames(transaction model.Transaction, folderPath string) ([]string, error)
+
+	//ResourceContent 打开文件 path 的内容, 调用方负责关闭
+	ResourceContent(transaction model.Transaction, path string) (io.ReadCloser, error)
+
+	//SetResourceContent 写入文件 path 的内容, 文件不存在时创建, length 为 -1 表示长度未知
+	//返回实际写入的字节数
+	SetResourceContent(transaction model.Transaction, path string, content io.Reader,
+		contentType string, length int64) (int64, error)
+
+	//CreateFolder 创建文件夹 folderPath, 父文件夹不存在时返回 ErrConflict
+	CreateFolder(transaction model.Transaction, folderPath string) error
+
+	//RemoveObject 删除 path, 文件夹连同其子资源一起删除
+	RemoveObject(transaction model.Transaction, path string) error
+
+	//Move 将 path 移动到文件夹 toParentPath 下, 名称不变
+	Move(transaction model.Transaction, path string, toParentPath string) error
+
+	//Copy 将 path 复制为 destinationPath, 文件夹连同其子资源一起复制
+	Copy(transaction model.Transaction, path string, destinationPath string) error
+
+	//Rename 在同一文件夹下将 path 重命名为 name
+	Rename(transaction model.Transaction, path string, name string) error
+}
+
+//RangeReader 由可以只读取部分内容的 Store 实现, GET 请求带 Range 时不必读取整个文件
+type RangeReader interface {
+
+	//ResourceRange 打开文件 path 从 offset 开始的 length 字节, length 为 -1 时读取到结尾
+	ResourceRange(transaction model.Transaction, path string, offset int64, length int64) (io.ReadCloser, error)
+}
+
+//Redirector 由可以提供直接下载地址的 Store 实现, 客户端可以不经过服务器下载文件
+type Redirector interface {
+
+	//DownloadURL 返回文件 path 的下载地址
+	DownloadURL(transaction model.Transaction, path string) (string, error)
+}
+
+//ChildWalker 由可以分页列出文件夹的 Store 实现, 子资源逐个交给 fn, 不必一次取回整个文件夹
+type ChildWalker interface {
+
+	//WalkChildren 按顺序对文件夹 folderPath 下的每个子资源调用 fn, fn 返回错误时停止并返回该错误
+	WalkChildren(transaction model.Transaction, folderPath string,
+		fn func(name string, so *model.StoredObject) error) error
+}
+
+//ChildrenHasher 由可以直接给出子资源列表摘要的 Store 实现, 子资源增删或修改后摘要随之改变
+type ChildrenHasher interface {
+
+	//ChildrenHash 返回文件夹 folderPath 下子资源列表的摘要, 用于文件夹的 ETag
+	ChildrenHash(transaction model.Transaction, folderPath string) (string, error)
+}
+
+//BatchMover 由可以一次移动多个资源的 Store 实现, 逐个移动文件夹的子资源时减少请求次数
+type BatchMover interface {
+
+	//MoveAll 将 paths 移动到文件夹 toParentPath 下, 名称不变, 返回移动失败的路径和对应的错误
+	MoveAll(transaction model.Transaction, paths []string, toParentPath string) map[string]error
+}
+
+//TimeSetter 由可以修改资源时间的 Store 实现, 不实现时由服务器记录客户端设置的时间
+type TimeSetter interface {
+
+	//SetTimes 修改 path 的修改时间和创建时间, 零值表示不修改
+	SetTimes(transaction model.Transaction, path string, lastModified time.Time, creationDate time.Time) error
+}
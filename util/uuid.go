@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/rand"
+	"fmt"
 	"log"
 	"strconv"
 	"sync"
@@ -31,6 +33,18 @@ func NextId() int64 {
 func NextIdStr() string {
 	return strconv.FormatInt(DefaultIdWorker.nextId(), 10)
 }
+
+//RandomUUID 生成随机的 version 4 UUID, 用于不能被猜到的标识
+func RandomUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		log.Fatal("can not generate uuid: ", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 type worker struct {
 	mu           sync.Mutex
 	laststamp    int64
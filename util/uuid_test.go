@@ -1,9 +1,24 @@
 package util
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func TestNextIdStr(t *testing.T) {
 	// 雪花测试
 	println(NextIdStr())
 	println(NextId())
 }
+
+func TestRandomUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := RandomUUID()
+		if !pattern.MatchString(id) || seen[id] {
+			t.Fatalf("uuid %s", id)
+		}
+		seen[id] = true
+	}
+}